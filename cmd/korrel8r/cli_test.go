@@ -3,11 +3,14 @@
 package main_test
 
 import (
+	"encoding/json"
 	"os/exec"
 	"strings"
 	"testing"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/engine"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -85,3 +88,95 @@ func TestMain_rules(t *testing.T) {
 		})
 	}
 }
+
+func TestMain_rules_object(t *testing.T) {
+	for _, x := range []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"rules", "--start", "mock:foo", "--object", "-"},
+			want: "appx",
+		},
+		{
+			args: []string{"rules", "--start", "mock:foo", "--object", "-", "--explain"},
+			want: "appx\nappy  rule does not apply: field .app=xyz does not match y.*",
+		},
+	} {
+		t.Run(strings.Join(x.args, " "), func(t *testing.T) {
+			cmd := command(t, append(x.args, "-c", "testdata/when.yaml")...)
+			cmd.Stdin = strings.NewReader(`{"app": "xyz"}`)
+			out, err := cmd.Output()
+			require.NoError(t, test.ExecError(err))
+			assert.Equal(t, x.want, strings.TrimSpace(string(out)))
+		})
+	}
+}
+
+func TestMain_rules_lint(t *testing.T) {
+	wantError := `testdata/lint.yaml: error: rule bad: .Nonesuch: can't evaluate field Nonesuch
+testdata/lint.yaml: error: rule badwhen: when: .Spec.Nodename: can't evaluate field Nodename
+testdata/lint.yaml: error: rule badmapping: mapping name: .Spec.Nodename: can't evaluate field Nodename
+testdata/lint.yaml: error: rule badbidirectional/inverse: mapping fields.spec.nodeName: .Nme: can't evaluate field Nme`
+	wantWarning := "testdata/lint.yaml: warning: rule someclasses: .Spec.NodeName: can't evaluate field NodeName (Service.v1.)"
+
+	out, err := command(t, "rules", "lint", "-c", "testdata/lint.yaml").Output()
+	assert.Error(t, err)
+	assert.Equal(t, wantError, strings.TrimSpace(string(out)))
+
+	out, err = command(t, "rules", "lint", "--warnings", "-c", "testdata/lint.yaml").Output()
+	assert.Error(t, err)
+	assert.Equal(t, wantWarning+"\n"+wantError, strings.TrimSpace(string(out)))
+}
+
+func TestMain_rules_apply(t *testing.T) {
+	for _, x := range []struct {
+		rule, want, err string
+	}{
+		{rule: "appx", want: "mock:bar:xyz"},
+		{rule: "appy", err: "rule does not apply: field .app=xyz does not match y.*"},
+		{rule: "nonesuch", err: "rule not found: nonesuch"},
+	} {
+		t.Run(x.rule, func(t *testing.T) {
+			cmd := command(t, "rules", "apply", x.rule, "--object", "-", "-c", "testdata/when.yaml")
+			cmd.Stdin = strings.NewReader(`{"app": "xyz"}`)
+			cmd.Stderr = nil // Capture stderr in the exit error.
+			out, err := cmd.Output()
+			if x.err != "" {
+				assert.ErrorContains(t, test.ExecError(err), x.err)
+				return
+			}
+			require.NoError(t, test.ExecError(err))
+			assert.Equal(t, x.want, strings.TrimSpace(string(out)))
+		})
+	}
+}
+
+func TestMain_config_validate(t *testing.T) {
+	out, err := command(t, "config", "validate", "-c", "testdata/when.yaml").Output()
+	require.NoError(t, test.ExecError(err))
+	assert.Empty(t, strings.TrimSpace(string(out)))
+
+	out, err = command(t, "config", "validate", "-c", "testdata/invalid.yaml").Output()
+	assert.Error(t, err)
+	assert.Equal(t, `testdata/invalid.yaml:3: unknown key for log store: "lokistak", expecting one of [loki lokiStack token tokenFile username password clientCertificate clientKey proxy insecureSkipVerify header.*]
+testdata/invalid.yaml:6: rule badclass: class not found in domain k8s: "Pood"`, strings.TrimSpace(string(out)))
+}
+
+func TestMain_config_show(t *testing.T) {
+	out, err := command(t, "config", "show", "-c", "testdata/when.yaml", "-o", "json").Output()
+	require.NoError(t, test.ExecError(err))
+	var ec engine.EffectiveConfig
+	require.NoError(t, json.Unmarshal(out, &ec))
+	require.Len(t, ec.Rules, 2)
+	assert.Equal(t, "appy", ec.Rules[1].Name)
+	assert.Equal(t, engine.Origin{Source: "testdata/when.yaml", Line: 16}, ec.Rules[1].Origin)
+}
+
+func TestMain_config_schema(t *testing.T) {
+	out, err := command(t, "config", "schema").Output()
+	require.NoError(t, test.ExecError(err))
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(out, &schema))
+	assert.Equal(t, config.SchemaURI, schema["$schema"])
+}
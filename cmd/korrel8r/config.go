@@ -0,0 +1,73 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/engine"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Validate or show the configuration, or print the configuration schema",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file and all included files",
+	Long: `Validate the configuration file and all included files, without connecting to any stores.
+Checks YAML syntax and unknown fields, includes, aliases, store domains and keys,
+rule names, class names and templates.
+Prints each problem with its file and line, exits with an error if there are any problems.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configs := must.Must1(config.LoadProfile(*configFlag, *configProfileFlag))
+		problems := engine.Build().Domains(domains...).Check(configs)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stdout, p)
+		}
+		if len(problems) > 0 {
+			must.Must(fmt.Errorf("%v configuration problems", len(problems)))
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration, with the source of each rule and store",
+	Long: `Print the effective configuration after includes, profiles and aliases are applied.
+Rule classes are expanded, store templates are expanded and secrets are redacted.
+Each rule, store and the tuning section has an origin with the file or URL and line it came from.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		e, configs := newEngine()
+		newPrinter(os.Stdout).Print(e.EffectiveConfig(configs))
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for configuration files",
+	Long: `Print a JSON Schema for configuration files.
+Editors can use the schema to check configuration files and complete field names.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		storeKeys := map[string][]string{}
+		for _, d := range domains {
+			storeKeys[d.Name()] = engine.StoreKeys(d)
+		}
+		b := must.Must1(json.MarshalIndent(config.Schema(storeKeys), "", "  "))
+		fmt.Fprintln(os.Stdout, string(b))
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd, configShowCmd, configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
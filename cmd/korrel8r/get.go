@@ -28,7 +28,8 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			e, _ := newEngine()
 			q := must.Must1(e.Query(args[0]))
-			result := newPrinter(os.Stdout)
+			p := newPrinter(os.Stdout)
+			result := korrel8r.FuncAppender(func(o korrel8r.Object) { p.Print(must.Must1(e.Redact(q.Class(), o))) })
 			c := &korrel8r.Constraint{}
 			if *limitFlag > 0 {
 				c.Limit = limitFlag
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/korrel8r/korrel8r/internal/pkg/must"
 	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
 	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/discovery"
 	"github.com/korrel8r/korrel8r/pkg/domains/alert"
 	"github.com/korrel8r/korrel8r/pkg/domains/k8s"
 	logdomain "github.com/korrel8r/korrel8r/pkg/domains/log"
@@ -19,6 +21,7 @@ import (
 	"github.com/korrel8r/korrel8r/pkg/domains/netflow"
 	"github.com/korrel8r/korrel8r/pkg/domains/trace"
 	"github.com/korrel8r/korrel8r/pkg/engine"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"github.com/spf13/cobra"
 )
 
@@ -32,15 +35,22 @@ var (
 	}
 
 	// Global Flags
-	outputFlag  = rootCmd.PersistentFlags().StringP("output", "o", "yaml", "Output format: [json, json-pretty, yaml]")
-	verboseFlag = rootCmd.PersistentFlags().IntP("verbose", "v", 0, "Verbosity for logging (0 = notice, 1 = info, 2 = debug, 3 = trace)")
-	configFlag  = rootCmd.PersistentFlags().StringP("config", "c", getConfig(), "Configuration file")
-	panicFlag   = rootCmd.PersistentFlags().Bool("panic", false, "Panic on error")
+	outputFlag        = rootCmd.PersistentFlags().StringP("output", "o", "yaml", "Output format: [json, json-pretty, yaml]")
+	verboseFlag       = rootCmd.PersistentFlags().IntP("verbose", "v", 0, "Verbosity for logging (0 = notice, 1 = info, 2 = debug, 3 = trace)")
+	configFlag        = rootCmd.PersistentFlags().StringP("config", "c", getConfig(), "Configuration file")
+	configProfileFlag = rootCmd.PersistentFlags().String("config-profile", os.Getenv(configProfileEnv), "Configuration profile to apply")
+	discoverFlag      = rootCmd.PersistentFlags().Bool("discover", false, "Discover stores in the cluster for domains with no configured stores")
+	discoverRouteFlag = rootCmd.PersistentFlags().Bool("discover-routes", false, "Use Routes for discovered stores, for running outside the cluster")
+	panicFlag         = rootCmd.PersistentFlags().Bool("panic", false, "Panic on error")
+
+	// refresher is set if --discover is used.
+	refresher *discovery.Refresher
 )
 
 const (
-	configEnv     = "KORREL8R_CONFIG"
-	defaultConfig = "/etc/korrel8r/korrel8r.yaml"
+	configEnv        = "KORREL8R_CONFIG"
+	configProfileEnv = "KORREL8R_CONFIG_PROFILE"
+	defaultConfig    = "/etc/korrel8r/korrel8r.yaml"
 )
 
 func init() {
@@ -80,11 +90,27 @@ func main() {
 }
 
 func newEngine() (*engine.Engine, config.Configs) {
-	log.Info("Starting korrel8r", "version", build.Version, "configuration", *configFlag)
-	c := must.Must1(config.Load(*configFlag))
-	e := must.Must1(engine.Build().
-		Domains(k8s.Domain, logdomain.Domain, netflow.Domain, trace.Domain, alert.Domain, metric.Domain, mock.Domain("mock")).
-		Config(c).
-		Engine())
-	return e, c
+	b, c := newBuilder()
+	return must.Must1(b.Engine()), c
 }
+
+// newBuilder loads the configuration and returns a configured engine.Builder.
+func newBuilder() (*engine.Builder, config.Configs) {
+	log.Info("Starting korrel8r", "version", build.Version, "configuration", *configFlag, "profile", *configProfileFlag)
+	c := must.Must1(config.LoadProfile(*configFlag, *configProfileFlag))
+	if *discoverFlag {
+		refresher = &discovery.Refresher{
+			Client:  must.Must1(k8s.NewClient(nil)),
+			Options: discovery.Options{Routes: *discoverRouteFlag},
+		}
+		if _, err := refresher.Refresh(context.Background()); err != nil {
+			log.Error(err, "Discovering stores")
+		}
+		c = discovery.Merge(c, refresher.Stores())
+	}
+	b := engine.Build().Domains(domains...).Config(c)
+	return b, c
+}
+
+// domains known to the korrel8r command.
+var domains = []korrel8r.Domain{k8s.Domain, logdomain.Domain, netflow.Domain, trace.Domain, alert.Domain, metric.Domain, mock.Domain("mock")}
@@ -9,7 +9,6 @@ import (
 	"reflect"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/must"
-	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"sigs.k8s.io/yaml"
 )
 
@@ -51,9 +50,3 @@ func newPrinter(w io.Writer) printer {
 		return printer{}
 	}
 }
-
-func (p printer) Append(objects ...korrel8r.Object) {
-	for _, o := range objects {
-		p.Print(o)
-	}
-}
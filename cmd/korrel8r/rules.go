@@ -3,7 +3,11 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"slices"
@@ -12,6 +16,7 @@ import (
 	"github.com/korrel8r/korrel8r/internal/pkg/must"
 	"github.com/korrel8r/korrel8r/pkg/graph"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/rest"
 	"github.com/spf13/cobra"
 	"gonum.org/v1/gonum/graph/encoding/dot"
 )
@@ -20,6 +25,10 @@ var rulesCmd = &cobra.Command{
 	Use:   "rules",
 	Short: "List rules by start, goal or name",
 	Run: func(cmd *cobra.Command, args []string) {
+		if *ruleStats {
+			printRuleStats()
+			return
+		}
 		e, _ := newEngine()
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		defer w.Flush()
@@ -31,10 +40,28 @@ var rulesCmd = &cobra.Command{
 			goal = must.Must1(e.Class(*ruleGoal))
 		}
 		name := must.Must1(regexp.Compile(*ruleName))
+		var object korrel8r.Object
+		if *ruleObject != "" {
+			if start == nil {
+				must.Must(errors.New("--object requires --start to decode the object"))
+			}
+			object = must.Must1(start.Unmarshal(must.Must1(readFileOrStdin(*ruleObject))))
+		}
 		test := func(r korrel8r.Rule) bool {
-			return (start == nil || slices.Contains(r.Start(), start)) &&
+			if !((start == nil || slices.Contains(r.Start(), start)) &&
 				(goal == nil || slices.Contains(r.Goal(), goal)) &&
-				name.MatchString(r.Name())
+				name.MatchString(r.Name())) {
+				return false
+			}
+			if object != nil {
+				if err := korrel8r.RuleApplies(r, start, object); err != nil {
+					if *ruleExplain {
+						fmt.Fprintf(w, "%v\t%v\n", r, err)
+					}
+					return false
+				}
+			}
+			return true
 		}
 		if *ruleGraph {
 			g := e.Graph().Select(func(l *graph.Line) bool { return test(l.Rule) })
@@ -52,14 +79,41 @@ var rulesCmd = &cobra.Command{
 }
 
 var (
-	ruleStart, ruleGoal, ruleName *string
-	ruleGraph                     *bool
+	ruleStart, ruleGoal, ruleName, ruleObject *string
+	ruleGraph, ruleExplain, ruleStats         *bool
+	ruleURL                                   *string
 )
 
+// printRuleStats gets rule statistics from a running server and prints them as a table.
+func printRuleStats() {
+	u := must.Must1(url.JoinPath(*ruleURL, rest.BasePath, "rules", "stats"))
+	resp := must.Must1(http.Get(u))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		must.Must(fmt.Errorf("%v: %v", u, resp.Status))
+	}
+	var stats []rest.RuleStats
+	must.Must(json.NewDecoder(resp.Body).Decode(&stats))
+	name := must.Must1(regexp.Compile(*ruleName))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "RULE\tAPPLIED\tSKIPPED\tNOQUERY\tERRORS\tQUERIES\tCACHED\tRESULTS\tOBJECTS\tLATENCY")
+	for _, rs := range stats {
+		if name.MatchString(rs.Name) {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+				rs.Name, rs.Applied, rs.Skipped, rs.NoQuery, rs.Errors, rs.Queries, rs.Cached, rs.Results, rs.Objects, rs.Latency)
+		}
+	}
+}
+
 func init() {
 	ruleStart = rulesCmd.Flags().StringP("start", "s", "", "show rules with this start class")
 	ruleGoal = rulesCmd.Flags().StringP("goal", "g", "", "show rules with this goal class")
 	ruleName = rulesCmd.Flags().StringP("name", "n", "", "show rules with name matching this regexp")
 	ruleGraph = rulesCmd.Flags().Bool("graph", false, "write rule graph in graphviz format")
+	ruleObject = rulesCmd.Flags().String("object", "", "show rules that apply to the object in this file, '-' for stdin. Requires --start")
+	ruleExplain = rulesCmd.Flags().Bool("explain", false, "with --object, also show rules that do not apply and the reason")
+	ruleStats = rulesCmd.Flags().Bool("stats", false, "show cumulative rule statistics from a running korrel8r server")
+	ruleURL = rulesCmd.Flags().String("url", "http://localhost:8080", "URL of the korrel8r server for --stats")
 	rootCmd.AddCommand(rulesCmd)
 }
@@ -0,0 +1,70 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/spf13/cobra"
+)
+
+var rulesApplyCmd = &cobra.Command{
+	Use:   "apply RULE --object FILE|-",
+	Short: "Apply a single rule to a start object and print the generated queries",
+	Long: `Apply a single rule to a start object and print the generated queries.
+The object is decoded using the rule's start class, --class is required if the rule has more than one.
+If the rule does not apply, prints the reason and exits with an error.
+With --exec, also executes the queries and prints the number of results for each.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e, _ := newEngine()
+		rule := e.Rule(args[0])
+		if rule == nil {
+			must.Must(fmt.Errorf("rule not found: %v", args[0]))
+		}
+		var class korrel8r.Class
+		switch {
+		case *rulesApplyClass != "":
+			class = must.Must1(e.Class(*rulesApplyClass))
+			if !slices.Contains(rule.Start(), class) {
+				must.Must(fmt.Errorf("class %v is not a start class of rule %v", class, rule.Name()))
+			}
+		case len(rule.Start()) == 1:
+			class = rule.Start()[0]
+		default:
+			must.Must(fmt.Errorf("--class is required, rule %v has more than one start class", rule.Name()))
+		}
+		object := must.Must1(class.Unmarshal(must.Must1(readFileOrStdin(*rulesApplyObject))))
+		must.Must(korrel8r.RuleApplies(rule, class, object))
+		queries := must.Must1(korrel8r.ApplyRuleContext(context.Background(), rule, object))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+		for _, q := range queries {
+			if *rulesApplyExec {
+				result := korrel8r.NewResult(q.Class())
+				must.Must(e.Get(context.Background(), q, nil, result))
+				fmt.Fprintf(w, "%v\t%v\n", len(result.List()), q)
+			} else {
+				fmt.Fprintln(w, q)
+			}
+		}
+	},
+}
+
+var (
+	rulesApplyObject, rulesApplyClass *string
+	rulesApplyExec                    *bool
+)
+
+func init() {
+	rulesApplyObject = rulesApplyCmd.Flags().String("object", "-", "read the start object from this file, '-' for stdin")
+	rulesApplyClass = rulesApplyCmd.Flags().String("class", "", "class of the start object, if the rule has more than one start class")
+	rulesApplyExec = rulesApplyCmd.Flags().BoolP("exec", "x", false, "execute generated queries and print result counts")
+	rulesCmd.AddCommand(rulesApplyCmd)
+}
@@ -0,0 +1,46 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/pkg/rules"
+	"github.com/spf13/cobra"
+)
+
+var rulesLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check rule templates against the types of their start classes",
+	Long: `Check rule templates against the types of their start classes.
+Checks query templates, mapping and bidirectional field paths, and when.fields expressions.
+Reports unknown fields and bad template function calls, with the source file and rule name.
+An error means the rule can never apply, a warning means it fails for some start classes.
+Exits with an error if any errors are found.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		b, _ := newBuilder()
+		_ = must.Must1(b.Engine())
+		errorCount := 0
+		for _, d := range b.Diagnostics() {
+			if d.Severity == rules.Error {
+				errorCount++
+			}
+			if *rulesLintWarnings || d.Severity == rules.Error {
+				fmt.Fprintln(os.Stdout, d)
+			}
+		}
+		if errorCount > 0 {
+			must.Must(fmt.Errorf("%v rule errors", errorCount))
+		}
+	},
+}
+
+var rulesLintWarnings *bool
+
+func init() {
+	rulesLintWarnings = rulesLintCmd.Flags().BoolP("warnings", "w", false, "show warnings as well as errors")
+	rulesCmd.AddCommand(rulesLintCmd)
+}
@@ -20,12 +20,7 @@ Reads stdin by default if neither --file nor --template is provided.
 Useful for testing rule and store templates.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if *templateString == "" { // Read from file
-			switch *templateFile {
-			case "", "-":
-				*templateString = string(must.Must1(io.ReadAll(os.Stdin)))
-			default:
-				*templateString = string(must.Must1(os.ReadFile(*templateFile)))
-			}
+			*templateString = string(must.Must1(readFileOrStdin(*templateFile)))
 		}
 		e, _ := newEngine()
 		t := template.Must(e.NewTemplate(*templateString).Parse(*templateString))
@@ -35,6 +30,16 @@ Useful for testing rule and store templates.`,
 
 var templateFile, templateString *string
 
+// readFileOrStdin reads the named file, or stdin if name is "" or "-".
+func readFileOrStdin(name string) ([]byte, error) {
+	switch name {
+	case "", "-":
+		return io.ReadAll(os.Stdin)
+	default:
+		return os.ReadFile(name)
+	}
+}
+
 func init() {
 	templateFile = templateCmd.Flags().StringP("file", "f", "", "read template from file")
 	templateString = templateCmd.Flags().StringP("template", "t", "", "use template string")
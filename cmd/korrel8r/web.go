@@ -3,25 +3,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/korrel8r/korrel8r/internal/pkg/build"
 	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/config/crd"
+	"github.com/korrel8r/korrel8r/pkg/discovery"
+	"github.com/korrel8r/korrel8r/pkg/domains/k8s"
+	"github.com/korrel8r/korrel8r/pkg/engine"
 	"github.com/korrel8r/korrel8r/pkg/rest"
 	"github.com/korrel8r/korrel8r/pkg/rest/docs"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var webCmd = &cobra.Command{
 	Use:   "web [flags]",
 	Short: "Start REST server. Listening address must be  provided via --http or --https.",
 	Args:  cobra.NoArgs,
-	Run: func(_ *cobra.Command, args []string) {
+	Run: func(cmd *cobra.Command, args []string) {
 		if *specFlag != "" {
 			spec := docs.SwaggerInfo.ReadDoc()
 			if *specFlag == "-" {
@@ -54,13 +62,47 @@ var webCmd = &cobra.Command{
 			}
 		}
 
-		engine, configs := newEngine()
+		e, configs := newEngine()
 		gin.SetMode(gin.ReleaseMode)
 		router := gin.New()
 		router.Use(gin.Recovery())
-		r, err := rest.New(engine, configs, router)
+		r, err := rest.New(e, configs, router)
 		must.Must(err)
 		defer r.Close()
+		reload := reloader(r)
+		var w *crd.Watcher
+		if *watchFlag {
+			ns := must.Must1(k8s.Namespace())
+			w = &crd.Watcher{
+				Client:           must.Must1(newWatchClient()),
+				Namespace:        ns,
+				Base:             configs,
+				Reload:           reload,
+				TrustedNamespace: ns,
+			}
+			if cmd.Flags().Changed("watch-namespace") {
+				w.Namespace = *watchNamespaceFlag
+			}
+			// Load resources before serving requests.
+			if _, err := w.Sync(context.Background()); err != nil {
+				log.Error(err, "Loading configuration resources")
+			}
+			go func() { _ = w.Run(context.Background()) }()
+		}
+		if refresher != nil {
+			refresher.Interval = *discoverIntervalFlag
+			refresher.Update = func(stores []config.Store) {
+				configs := discovery.Merge(configs, stores)
+				if w != nil {
+					if err := w.SetBase(context.Background(), configs); err != nil {
+						log.Error(err, "Loading configuration resources")
+					}
+				} else {
+					reload(configs)
+				}
+			}
+			go func() { _ = refresher.Run(context.Background()) }()
+		}
 		s.Handler = router
 		if *profileFlag {
 			pprof.Register(router)
@@ -75,11 +117,39 @@ var webCmd = &cobra.Command{
 	},
 }
 
+// reloader returns a function to rebuild the engine used by the REST API.
+func reloader(r *rest.API) crd.ReloadFunc {
+	return func(configs config.Configs) (bool, []config.Problem) {
+		if problems := engine.Build().Domains(domains...).Check(configs); len(problems) > 0 {
+			return false, problems
+		}
+		e, err := engine.Build().Domains(domains...).Config(configs).Engine()
+		if err != nil {
+			log.Error(err, "Reloading configuration")
+			return false, nil
+		}
+		r.Update(e, configs)
+		log.Info("Reloaded configuration", "configs", len(configs))
+		return true, e.StoreProblems()
+	}
+}
+
+func newWatchClient() (client.WithWatch, error) {
+	cfg, err := k8s.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.NewWithWatch(cfg, client.Options{})
+}
+
 var (
-	httpFlag, httpsFlag *string
-	certFlag, keyFlag   *string
-	specFlag            *string
-	profileFlag         *bool
+	httpFlag, httpsFlag  *string
+	watchFlag            *bool
+	watchNamespaceFlag   *string
+	discoverIntervalFlag *time.Duration
+	certFlag, keyFlag    *string
+	specFlag             *string
+	profileFlag          *bool
 )
 
 const (
@@ -93,6 +163,9 @@ func init() {
 	certFlag = webCmd.Flags().String("cert", "", "TLS certificate file (PEM format) for https")
 	keyFlag = webCmd.Flags().String("key", "", "Private key (PEM format) for https")
 	specFlag = webCmd.Flags().String("spec", "", "Dump swagger spec to a file, '-' for stdout.")
+	watchFlag = webCmd.Flags().Bool("watch-config", false, "Watch Korrel8rConfig resources in the cluster, reload configuration when they change.")
+	watchNamespaceFlag = webCmd.Flags().String("watch-namespace", "", "Namespace to watch for Korrel8rConfig resources, default is the korrel8r namespace, empty for all namespaces.")
+	discoverIntervalFlag = webCmd.Flags().Duration("discover-interval", 5*time.Minute, "Interval between store discovery refreshes, with --discover.")
 	profileDefault, _ := strconv.ParseBool(os.Getenv(profileEnv))
 	profileFlag = webCmd.Flags().Bool("profile", profileDefault, "Enable HTTP profiling, see https://pkg.go.dev/net/http/pprof")
 }
@@ -125,6 +125,19 @@ func TestMain_server_graph(t *testing.T) {
 	require.Equal(t, testResponse, rest.Normalize(got))
 }
 
+func TestMain_server_rules_stats(t *testing.T) {
+	u := startServer(t, http.DefaultClient, "http", "-c", "testdata/korrel8r.yaml")
+	_, err := request(t, http.DefaultClient, "POST", u.String()+"/graphs/neighbours", testRequest)
+	require.NoError(t, err)
+	out, err := command(t, "rules", "--stats", "--url", (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()).Output()
+	require.NoError(t, test.ExecError(err))
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 3, string(out))
+	assert.Regexp(t, `^RULE +APPLIED +SKIPPED +NOQUERY +ERRORS +QUERIES +CACHED +RESULTS +OBJECTS +LATENCY$`, lines[0])
+	assert.Regexp(t, `^barfoo +0 +0 +0 +0 +0 +0 +0 +0 +0s$`, lines[1])
+	assert.Regexp(t, `^foobar +1 +0 +0 +0 +1 +0 +1 +1 +[0-9.]+.?s$`, lines[2])
+}
+
 func TestMain_concurrent_requests(t *testing.T) {
 	u := startServer(t, http.DefaultClient, "http", "-c", "testdata/korrel8r.yaml").String()
 	workers := sync.WaitGroup{}
@@ -9,8 +9,11 @@ import (
 	"github.com/korrel8r/korrel8r/pkg/domains/log"
 	"github.com/korrel8r/korrel8r/pkg/domains/metric"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -61,8 +64,25 @@ func TestSelectorToPods(t *testing.T) {
 			Spec:       podx.Spec,
 		}}
 	class := k8s.ClassOf(podx)
-	testTraverse(t, e, k8s.ClassOf(d), class, []korrel8r.Object{d},
-		k8s.NewQuery(class, "ns", "", client.MatchingLabels{"test": "testme"}, nil))
+	selectorQuery := func(selector string) korrel8r.Query {
+		q := k8s.NewQuery(class, "ns", "", nil, nil)
+		q.Selector = selector
+		return q
+	}
+	testTraverse(t, e, k8s.ClassOf(d), class, []korrel8r.Object{d}, selectorQuery("test=testme"))
+
+	// Deployment with set-based expressions.
+	d2 := d.DeepCopy()
+	d2.Spec.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{
+		{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+		{Key: "canary", Operator: metav1.LabelSelectorOpDoesNotExist},
+	}
+	testTraverse(t, e, k8s.ClassOf(d2), class, []korrel8r.Object{d2}, selectorQuery("!canary,test=testme,tier in (a,b)"))
+
+	// Service with a map selector.
+	svc := k8s.New[corev1.Service]("ns", "x")
+	svc.Spec.Selector = labels
+	testTraverse(t, e, k8s.ClassOf(svc), class, []korrel8r.Object{svc}, selectorQuery("test=testme"))
 }
 
 func TestK8sEvent(t *testing.T) {
@@ -90,3 +110,51 @@ func TestK8sAllToMetric(t *testing.T) {
 	want := metric.Query("{namespace=\"aNamespace\",pod=\"foo\"}")
 	testTraverse(t, e, k8s.ClassOf(pod), want.Class(), []korrel8r.Object{pod}, want)
 }
+
+func TestK8sTopology(t *testing.T) {
+	d := k8s.New[appsv1.Deployment]("ns", "d")
+	d.UID = "d-uid"
+	d.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}}
+	rs := k8s.New[appsv1.ReplicaSet]("ns", "rs")
+	rs.UID = "rs-uid"
+	rs.Spec.Selector = d.Spec.Selector
+	rs.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))}
+	pod := k8s.New[corev1.Pod]("ns", "pod")
+	pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(rs, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+	pod.Labels = map[string]string{"app": "x"}
+	other := k8s.New[corev1.Pod]("ns", "other")
+	other.Labels = pod.Labels
+	svc := k8s.New[corev1.Service]("ns", "svc")
+	slice := k8s.New[discoveryv1.EndpointSlice]("ns", "svc-abc")
+	slice.Labels = map[string]string{discoveryv1.LabelServiceName: "svc"}
+	slice.Endpoints = []discoveryv1.Endpoint{{TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: "pod"}}}
+	e := setup(d, rs, pod, other, slice)
+	podQuery := k8s.NewQuery(k8s.ClassOf(pod), "ns", "pod", nil, nil)
+
+	t.Run("WorkloadToOwner", func(t *testing.T) {
+		want := k8s.NewQuery(k8s.ClassOf(d), "ns", "d", nil, nil)
+		testTraverse(t, e, k8s.ClassOf(pod), k8s.ClassOf(d), []korrel8r.Object{pod}, want)
+	})
+	t.Run("OwnerToPods", func(t *testing.T) {
+		testTraverse(t, e, k8s.ClassOf(d), k8s.ClassOf(pod), []korrel8r.Object{d}, podQuery)
+	})
+	t.Run("ServiceToPods", func(t *testing.T) {
+		testTraverse(t, e, k8s.ClassOf(svc), k8s.ClassOf(pod), []korrel8r.Object{svc}, podQuery)
+	})
+}
+
+func TestK8sRedactSecret(t *testing.T) {
+	e := setup()
+	secret := k8s.New[corev1.Secret]("ns", "x")
+	secret.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"password":"aHVzaA=="}}`}
+	secret.Data = map[string][]byte{"password": []byte("hush")}
+	secret.StringData = map[string]string{"token": "s3cr3t"}
+	v, err := e.Redact(k8s.ClassOf(secret), secret)
+	require.NoError(t, err)
+	m := v.(map[string]any)
+	assert.NotContains(t, m, "data")
+	assert.NotContains(t, m, "stringData")
+	metadata := m["metadata"].(map[string]any)
+	assert.Empty(t, metadata["annotations"])
+	assert.Equal(t, "x", metadata["name"])
+}
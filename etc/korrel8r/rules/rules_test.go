@@ -27,10 +27,11 @@ import (
 	"golang.org/x/exp/maps"
 	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
-func setup() *engine.Engine {
+func setup(objects ...client.Object) *engine.Engine {
 	configs, err := config.Load("all.yaml")
 	if err != nil {
 		panic(err)
@@ -38,7 +39,7 @@ func setup() *engine.Engine {
 	for _, c := range configs {
 		c.Stores = nil // Use fake stores, not configured defaults.
 	}
-	c := fake.NewClientBuilder().WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(k8s.Scheme)).Build()
+	c := fake.NewClientBuilder().WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(k8s.Scheme)).WithObjects(objects...).Build()
 	s, err := k8s.NewStore(c, &rest.Config{})
 	if err != nil {
 		panic(err)
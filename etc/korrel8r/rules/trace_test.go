@@ -18,21 +18,30 @@ func Test_TraceToPod(t *testing.T) {
 		rule  string
 		start *trace.Span
 		want  string
+		err   string
 	}{
 		{
-			rule: "TraceToPod",
+			rule: "TracePod/forward",
 			start: &trace.Span{
 				Context:    trace.SpanContext{TraceID: "232323", SpanID: "3d48369744164bd0"},
 				Attributes: map[string]any{"k8s.namespace.name": "tracing-app-k6", "k8s.pod.name": "bar"},
 			},
 			want: `k8s:Pod.v1.:{"namespace":"tracing-app-k6","name":"bar"}`,
 		},
+		{
+			rule:  "TracePod/forward",
+			start: &trace.Span{Attributes: map[string]any{"k8s.namespace.name": "tracing-app-k6"}},
+			err:   `No query generated: field .Attributes["k8s.pod.name"] is empty`,
+		},
 	} {
 		t.Run(x.rule, func(t *testing.T) {
 			tested(x.rule)
 			got, err := e.Rule(x.rule).Apply(x.start)
-			assert.NoError(t, err)
-			assert.Equal(t, x.want, got.String())
+			if x.err != "" {
+				assert.EqualError(t, err, x.err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, x.want, got.String())
+			}
 		})
 	}
 }
@@ -43,27 +52,35 @@ func Test_TraceFromPod(t *testing.T) {
 		rule  string
 		start k8s.Object
 		want  string
+		err   string
 	}{
 		{
-			rule:  "PodToTrace",
+			rule:  "TracePod/inverse",
 			start: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}},
 			want:  `trace:span:{resource.k8s.namespace.name="bar"&&resource.k8s.pod.name="foo"}`,
 		},
 		{
-			rule:  "PodToTrace",
+			rule:  "TracePod/inverse",
 			start: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
 			want:  `trace:span:{resource.k8s.namespace.name="bar"}`,
 		},
 		{
-			rule:  "PodToTrace",
+			rule:  "TracePod/inverse",
 			start: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
 			want:  `trace:span:{resource.k8s.pod.name="foo"}`,
 		},
+		{
+			rule:  "TracePod/inverse",
+			start: &corev1.Pod{},
+			err:   "No query generated: all fields are empty",
+		},
 	} {
 		t.Run(x.rule, func(t *testing.T) {
 			tested(x.rule)
 			got, err := e.Rule(x.rule).Apply(x.start)
-			if assert.NoError(t, err) {
+			if x.err != "" {
+				assert.EqualError(t, err, x.err)
+			} else if assert.NoError(t, err) {
 				assert.Equal(t, x.want, got.String())
 			}
 		})
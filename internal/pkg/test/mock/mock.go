@@ -6,6 +6,7 @@
 package mock
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -17,11 +18,13 @@ import (
 
 var (
 	// Validate implementation of interfaces.
-	_ korrel8r.Domain = Domain("")
-	_ korrel8r.Class  = Domain("").Class("")
-	_ korrel8r.Query  = Query{}
-	_ korrel8r.Rule   = &Rule{}
-	_ korrel8r.Store  = &Store{}
+	_ korrel8r.Domain       = Domain("")
+	_ korrel8r.QueryBuilder = Domain("")
+	_ korrel8r.Class        = Domain("").Class("")
+	_ korrel8r.Query        = Query{}
+	_ korrel8r.Rule         = &Rule{}
+	_ korrel8r.MultiRule    = &MultiRule{}
+	_ korrel8r.Store        = &Store{}
 )
 
 type Object any // mock.Object is any JSON-marshalable object.
@@ -40,6 +43,16 @@ func (d Domain) Query(s string) (korrel8r.Query, error) {
 	return NewQuery(class, data), err
 }
 
+// BuildQuery builds a query with data "k1=v1,k2=v2..." with parameters sorted by name.
+func (d Domain) BuildQuery(c korrel8r.Class, params map[string]string) (korrel8r.Query, error) {
+	var kv []string
+	for k, v := range params {
+		kv = append(kv, k+"="+v)
+	}
+	slices.Sort(kv)
+	return NewQuery(c, strings.Join(kv, ",")), nil
+}
+
 func Domains(names ...string) []korrel8r.Domain {
 	var domains []korrel8r.Domain
 	for _, name := range names {
@@ -108,6 +121,31 @@ func (r *Rule) Name() string                                        { return r.n
 func (r *Rule) Apply(start korrel8r.Object) (korrel8r.Query, error) { return r.apply(start) }
 func (r *Rule) String() string                                      { return r.Name() }
 
+// MultiRule is a mock korrel8r.MultiRule that returns a list of queries.
+type MultiRule struct {
+	Rule
+	applyAll ApplyAllFunc
+}
+
+type ApplyAllFunc func(korrel8r.Object) ([]korrel8r.Query, error)
+
+// NewMultiRule constructs a rule with an apply function that returns multiple queries.
+func NewMultiRule(name string, start, goal []korrel8r.Class, applyAll ApplyAllFunc) *MultiRule {
+	r := &MultiRule{Rule: Rule{name: name, start: start, goal: goal}, applyAll: applyAll}
+	r.apply = func(o korrel8r.Object) (korrel8r.Query, error) {
+		qs, err := applyAll(o)
+		if len(qs) != 1 {
+			return nil, errors.Join(err, fmt.Errorf("expected 1 query, got %v", len(qs)))
+		}
+		return qs[0], err
+	}
+	return r
+}
+
+func (r *MultiRule) ApplyAll(start korrel8r.Object) ([]korrel8r.Query, error) {
+	return r.applyAll(start)
+}
+
 // RuleLess orders rules.
 func RuleLess(a, b korrel8r.Rule) int {
 	if a.Start()[0].Name() != b.Start()[0].Name() {
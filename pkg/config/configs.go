@@ -7,16 +7,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/logging"
 	"github.com/korrel8r/korrel8r/pkg/unique"
 	"sigs.k8s.io/yaml"
+	yamlv3 "sigs.k8s.io/yaml/goyaml.v3"
 )
 
 var log = logging.Log()
@@ -25,15 +28,27 @@ var log = logging.Log()
 //
 // If a configuration has an Include section, also loads all referenced configurations.
 // Relative paths in Include are relative to the location of file containing them.
-func Load(fileOrURL string) (Configs, error) {
+// Include paths can be glob patterns, see [filepath.Match], matching files are loaded in lexical order.
+func Load(fileOrURL string) (Configs, error) { return LoadProfile(fileOrURL, "") }
+
+// LoadProfile is like [Load], and applies the named profile if profile is not empty.
+//
+// Profiles with the selected name are applied in the order they were loaded, see [Profile].
+// Rules and stores added or modified by a profile are moved to a new [Config]
+// with a Source that names the profile, to show where they came from.
+func LoadProfile(fileOrURL, profile string) (Configs, error) {
 	l := loader{loaded: unique.NewSet[string]()}
 	if err := l.load(fileOrURL); err != nil {
 		return nil, err
 	}
-	if err := expand(l.configs); err != nil {
+	configs, err := applyProfile(l.configs, profile)
+	if err != nil {
 		return nil, err
 	}
-	return l.configs, nil
+	if err := Expand(configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
 }
 
 type loader struct {
@@ -41,8 +56,9 @@ type loader struct {
 	configs Configs
 }
 
-// Expand aliases in all rules.
-func expand(configs Configs) error {
+// Expand aliases in all rules and redactions, and remove the alias definitions.
+// [Load] expands aliases, this is only needed for configurations that are not loaded from files.
+func Expand(configs Configs) error {
 	// Gather am first.
 	am := aliasMap{}
 	for i := range configs {
@@ -79,6 +95,23 @@ func expand(configs Configs) error {
 			}
 			r.Start.Classes = am.Expand(r.Start.Domain, r.Start.Classes)
 			r.Goal.Classes = am.Expand(r.Goal.Domain, r.Goal.Classes)
+			if r.When != nil {
+				r.When.Classes = am.Expand(r.Start.Domain, r.When.Classes)
+			}
+		}
+		for i := range c.Redact {
+			r := &c.Redact[i]
+			r.Classes = am.Expand(r.Domain, r.Classes)
+		}
+		// Expand aliases in store cache lists.
+		for _, s := range c.Stores {
+			if v := s[StoreKeyCache]; v != "" {
+				classes := strings.Split(v, ",")
+				for i := range classes {
+					classes[i] = strings.TrimSpace(classes[i])
+				}
+				s[StoreKeyCache] = strings.Join(am.Expand(s[StoreKeyDomain], classes), ",")
+			}
 		}
 	}
 
@@ -99,19 +132,41 @@ func (l *loader) load(source string) error {
 	if err := yaml.UnmarshalStrict(b, &c); err != nil {
 		return fmt.Errorf("%v: %w", source, err)
 	}
+	c.Lines = parseLines(b)
 	if len(l.configs) > 0 && c.Tuning != nil {
 		return fmt.Errorf("Unexpected tuning section in included configuration: %v", source)
 	}
 	l.configs = append(l.configs, c)
 	for _, s := range c.Include {
-		ref := resolve(source, s)
-		if err := l.load(ref); err != nil {
-			return err
+		refs, err := glob(resolve(source, s))
+		if err != nil {
+			return fmt.Errorf("%v: include %q: %w", source, s, err)
+		}
+		for _, ref := range refs {
+			if err := l.load(ref); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// glob expands a file pattern to a sorted list of matching files.
+// URLs and paths without pattern characters are returned unchanged.
+func glob(ref string) ([]string, error) {
+	if u, err := url.Parse(ref); (err == nil && u.IsAbs()) || !strings.ContainsAny(ref, "*?[") {
+		return []string{ref}, nil
+	}
+	matches, err := filepath.Glob(ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no files match pattern")
+	}
+	return matches, nil // Glob returns sorted matches.
+}
+
 // map of domain names to alias names with class name lists
 type aliasMap map[string]map[string][]string
 
@@ -166,6 +221,10 @@ func readFileOrURL(source string) ([]byte, error) {
 	}
 }
 
+// ReadRelative reads a file or URL reference relative to a base configuration file or URL,
+// in the same way as the `include` section.
+func ReadRelative(base, ref string) ([]byte, error) { return readFileOrURL(resolve(base, ref)) }
+
 func resolve(base, ref string) string {
 	if filepath.IsAbs(ref) {
 		return ref
@@ -180,3 +239,100 @@ func resolve(base, ref string) string {
 	}
 	return filepath.Join(filepath.Dir(base), ref)
 }
+
+// Lines are the line numbers of items in a configuration source, only used for messages.
+type Lines struct {
+	// Sections has the line numbers of list items in the top level sections (rules, stores, redact...),
+	// for example Sections["rules"][2] is the line of the third rule.
+	// A section that is not a list, like tuning, has a single line number for the section.
+	Sections map[string][]int
+	// Rules has the line number of each rule, by rule name.
+	Rules map[string]int
+}
+
+// Section returns the line of item i in a section, or 0 if not known. Safe to call with l == nil.
+func (l *Lines) Section(section string, i int) int {
+	if l != nil && i < len(l.Sections[section]) {
+		return l.Sections[section][i]
+	}
+	return 0
+}
+
+// Rule returns the line of a rule, or 0 if not known. Safe to call with l == nil.
+func (l *Lines) Rule(name string) int {
+	if l != nil {
+		return l.Rules[name]
+	}
+	return 0
+}
+
+// SectionLines reads a configuration file or URL and returns [Lines.Sections].
+// Returns nil if the source can't be read or parsed.
+// Configurations returned by [Load] already have [Config.Lines].
+func SectionLines(source string) map[string][]int {
+	if l := readLines(source); l != nil {
+		return l.Sections
+	}
+	return nil
+}
+
+// RuleLines reads a configuration file or URL and returns [Lines.Rules].
+// Returns nil if the source can't be read or parsed.
+// Configurations returned by [Load] already have [Config.Lines].
+func RuleLines(source string) map[string]int {
+	if l := readLines(source); l != nil {
+		return l.Rules
+	}
+	return nil
+}
+
+func readLines(source string) *Lines {
+	b, err := readFileOrURL(source)
+	if err != nil {
+		return nil
+	}
+	return parseLines(b)
+}
+
+// parseLines returns the line numbers of items in a configuration, or nil if it can't be parsed.
+func parseLines(b []byte) *Lines {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(b, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return nil
+	}
+	l := &Lines{Sections: map[string][]int{}, Rules: map[string]int{}}
+	m := doc.Content[0].Content
+	for i := 0; i+1 < len(m); i += 2 {
+		section, value := m[i].Value, m[i+1]
+		switch value.Kind {
+		case yamlv3.SequenceNode:
+			for _, item := range value.Content {
+				l.Sections[section] = append(l.Sections[section], item.Line)
+				if section == "rules" {
+					for j := 0; j+1 < len(item.Content); j += 2 {
+						if item.Content[j].Value == "name" {
+							l.Rules[item.Content[j+1].Value] = item.Line
+						}
+					}
+				}
+			}
+		case yamlv3.MappingNode: // Single item section like tuning, use the line of the key.
+			l.Sections[section] = []int{m[i].Line}
+		}
+	}
+	return l
+}
+
+// Problem is a configuration problem found by validation, with its location.
+type Problem struct {
+	Source  string // Source file or URL.
+	Line    int    // Line number in source, 0 if not known.
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%v:%v: %v", p.Source, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%v: %v", p.Source, p.Message)
+}
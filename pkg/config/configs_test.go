@@ -38,7 +38,78 @@ func TestLoad(t *testing.T) {
 					Result: ResultSpec{Query: "blah"}}},
 		},
 	}
-	assert.Equal(t, want, c)
+	assert.Equal(t, &Lines{
+		Sections: map[string][]int{"aliases": {2}, "rules": {7}},
+		Rules:    map[string]int{"rule2": 7},
+	}, c[2].Lines)
+	assert.Equal(t, want, clearLines(c))
+}
+
+// clearLines clears [Config.Lines] to compare configurations.
+func clearLines(configs Configs) Configs {
+	for i := range configs {
+		configs[i].Lines = nil
+	}
+	return configs
+}
+
+func TestLoad_glob(t *testing.T) {
+	c, err := Load("testdata/glob.yaml")
+	require.NoError(t, err)
+	var sources []string
+	for _, c := range c {
+		sources = append(sources, c.Source)
+	}
+	assert.Equal(t, []string{"testdata/glob.yaml", "testdata/config1.yaml", "testdata/config2.yaml"}, sources)
+
+	_, err = Load("testdata/glob-nomatch.yaml")
+	assert.EqualError(t, err, `testdata/glob-nomatch.yaml: include "nomatch*.yaml": no files match pattern`)
+}
+
+func TestLoadProfile(t *testing.T) {
+	c, err := LoadProfile("testdata/profiles.yaml", "prod")
+	require.NoError(t, err)
+	want := Configs{
+		{
+			Source:  "testdata/profiles.yaml",
+			Include: []string{"profiles-rules.yaml"},
+			Tuning: &Tuning{
+				RequestTimeout: Duration{Duration: 10 * time.Second},
+				Domains:        map[string]StoreTuning{"foo": {Limit: 10}},
+			},
+			Stores: []Store{{"domain": "bar", "url": "https://bar.dev"}},
+		},
+		{Source: "testdata/profiles-rules.yaml", Rules: []Rule{}},
+		{
+			Source: "testdata/profiles.yaml [profile prod]",
+			Rules: []Rule{{
+				Name:   "r1",
+				Start:  ClassSpec{Domain: "foo", Classes: []string{"a"}},
+				Goal:   ClassSpec{Domain: "bar", Classes: []string{"q"}},
+				Result: ResultSpec{Query: "prod"},
+			}},
+			Stores: []Store{
+				{"domain": "foo", "url": "https://foo.prod", "header.X-Tenant": "prod"},
+				{"domain": "foo", "cluster": "east", "url": "https://foo.east.prod"},
+				{"domain": "baz", "url": "https://baz.prod"},
+			},
+		},
+	}
+	assert.Equal(t, want, clearLines(c))
+
+	c, err = LoadProfile("testdata/profiles.yaml", "staging")
+	require.NoError(t, err)
+	require.Len(t, c, 2)
+	assert.Len(t, c[1].Rules, 1)
+	assert.Len(t, c[0].Stores, 3)
+
+	c, err = Load("testdata/profiles.yaml")
+	require.NoError(t, err)
+	assert.Len(t, c[1].Rules, 2)
+	assert.Nil(t, c[0].Profiles)
+
+	_, err = LoadProfile("testdata/profiles.yaml", "nonesuch")
+	assert.EqualError(t, err, "profile not found: nonesuch")
 }
 
 func TestLoad_bad_tuning(t *testing.T) {
@@ -62,9 +133,10 @@ func TestConfigs_Expand(t *testing.T) {
 					Result: ResultSpec{Query: "dummy"},
 				},
 			},
+			Stores: []Store{{StoreKeyDomain: "foo", StoreKeyCache: "y, b"}},
 		},
 	}
-	require.NoError(t, expand(c))
+	require.NoError(t, Expand(c))
 	want := Configs{
 		{
 			Rules: []Rule{
@@ -75,6 +147,7 @@ func TestConfigs_Expand(t *testing.T) {
 					Result: ResultSpec{Query: "dummy"},
 				},
 			},
+			Stores: []Store{{StoreKeyDomain: "foo", StoreKeyCache: "p,q,a,b"}},
 		},
 	}
 	assert.Equal(t, want, c)
@@ -97,7 +170,7 @@ func TestConfigs_Expand_sameGroupDifferentDomain(t *testing.T) {
 			},
 		},
 	}
-	require.NoError(t, expand(c))
+	require.NoError(t, Expand(c))
 	want := Configs{
 		{
 			Rules: []Rule{{
@@ -108,3 +181,13 @@ func TestConfigs_Expand_sameGroupDifferentDomain(t *testing.T) {
 		}}
 	assert.Equal(t, want, c)
 }
+
+func TestSectionLines(t *testing.T) {
+	assert.Equal(t, map[string][]int{"aliases": {2}, "rules": {7}}, SectionLines("testdata/config2.yaml"))
+	assert.Nil(t, SectionLines("testdata/nonesuch.yaml"))
+}
+
+func TestRuleLines(t *testing.T) {
+	assert.Equal(t, map[string]int{"rule2": 7}, RuleLines("testdata/config2.yaml"))
+	assert.Nil(t, RuleLines("testdata/nonesuch.yaml"))
+}
@@ -0,0 +1,96 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+// Package crd configures korrel8r from Korrel8rConfig custom resources in a cluster.
+//
+// A Korrel8rConfig resource has a spec with the same sections as a configuration file,
+// except for `include` and `profiles`. See the custom resource definition in config/crd.
+//
+// A [Watcher] reloads the configuration when resources change,
+// and reports problems in the resource status.
+package crd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersionKind of the Korrel8rConfig custom resource.
+var GroupVersionKind = schema.GroupVersionKind{Group: "korrel8r.openshift.io", Version: "v1alpha1", Kind: "Korrel8rConfig"}
+
+// Korrel8rConfig is a custom resource containing korrel8r configuration.
+type Korrel8rConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   config.Config `json:"spec,omitempty"`
+	Status Status        `json:"status,omitempty"`
+}
+
+// Status of a Korrel8rConfig resource.
+type Status struct {
+	// Conditions has a single ConditionReady condition.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionReady is true if the resource configuration was loaded without problems.
+const ConditionReady = "Ready"
+
+// Reasons for the ConditionReady status.
+const (
+	ReasonApplied    = "Applied"    // Configuration is in use.
+	ReasonInvalid    = "Invalid"    // Configuration has problems, it is not in use.
+	ReasonNotApplied = "NotApplied" // Configuration is valid, not in use because of problems in other configuration.
+	ReasonStoreError = "StoreError" // Configuration is in use, some stores failed to connect.
+)
+
+// Source returns the configuration source name for a resource, used in [config.Config] and [config.Problem].
+func Source(o metav1.Object) string {
+	return fmt.Sprintf("%v/%v/%v", strings.ToLower(GroupVersionKind.Kind), o.GetNamespace(), o.GetName())
+}
+
+// FromUnstructured converts an unstructured resource, it is an error if there are unknown fields.
+func FromUnstructured(u *unstructured.Unstructured) (*Korrel8rConfig, error) {
+	k := &Korrel8rConfig{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(u.Object, k, true); err != nil {
+		return nil, err
+	}
+	k.Spec.Source = Source(k)
+	return k, nil
+}
+
+// Configs returns configurations from resources, with aliases expanded.
+// Resources with problems are not included in the result.
+func Configs(resources []*Korrel8rConfig) (configs config.Configs, problems []config.Problem) {
+	tuning := ""
+	for _, k := range resources {
+		c := k.Spec
+		switch {
+		case len(c.Include) > 0:
+			problems = append(problems, config.Problem{Source: c.Source, Message: "include is not allowed in a resource"})
+			continue
+		case len(c.Profiles) > 0:
+			problems = append(problems, config.Problem{Source: c.Source, Message: "profiles are not allowed in a resource"})
+			continue
+		case c.Tuning != nil && tuning != "":
+			problems = append(problems, config.Problem{Source: c.Source, Message: "tuning is already set by " + tuning})
+			continue
+		case c.Tuning != nil:
+			tuning = c.Source
+		}
+		configs = append(configs, c)
+	}
+	if err := config.Expand(configs); err != nil {
+		// Can't tell which resource is at fault, report for all.
+		for _, c := range configs {
+			problems = append(problems, config.Problem{Source: c.Source, Message: err.Error()})
+		}
+		return nil, problems
+	}
+	return configs, problems
+}
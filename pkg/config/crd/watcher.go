@@ -0,0 +1,250 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package crd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/logging"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var log = logging.Log()
+
+// ReloadFunc loads a new configuration.
+// Returns true if the configuration is now in use, and any problems found.
+// Problems are reported in the status of the resource that matches [config.Problem.Source].
+type ReloadFunc func(config.Configs) (applied bool, problems []config.Problem)
+
+// Watcher watches Korrel8rConfig resources and reloads configuration when they change.
+type Watcher struct {
+	Client    client.WithWatch
+	Namespace string         // Namespace to watch, empty for all namespaces.
+	Base      config.Configs // Base configuration, for example from files, combined with resources.
+	Reload    ReloadFunc
+	Retry     time.Duration // Delay before retrying after an error, default 10s.
+
+	// TrustedNamespace is the namespace of korrel8r. If set, resources in other namespaces are untrusted,
+	// see [Watcher.checkUntrusted].
+	TrustedNamespace string
+
+	lock        sync.Mutex
+	generations map[types.NamespacedName]int64 // Last loaded generation of each resource.
+}
+
+// Run the watcher until the context is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	retry := w.Retry
+	if retry == 0 {
+		retry = 10 * time.Second
+	}
+	for {
+		version, err := w.Sync(ctx)
+		if err == nil {
+			err = w.watch(ctx, version)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Error(err, "Watching configuration resources", "kind", GroupVersionKind.Kind)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retry):
+			}
+		}
+	}
+}
+
+// Sync lists resources, reloads the configuration and updates resource status.
+// Returns the resource version of the list.
+func (w *Watcher) Sync(ctx context.Context) (version string, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(GroupVersionKind.GroupVersion().WithKind(GroupVersionKind.Kind + "List"))
+	if err := w.Client.List(ctx, list, client.InNamespace(w.Namespace)); err != nil {
+		return "", err
+	}
+	w.generations = map[types.NamespacedName]int64{}
+	var (
+		resources []*Korrel8rConfig
+		problems  []config.Problem
+	)
+	for i := range list.Items {
+		u := &list.Items[i]
+		w.generations[client.ObjectKeyFromObject(u)] = u.GetGeneration()
+		k, err := FromUnstructured(u)
+		if err != nil {
+			problems = append(problems, config.Problem{Source: Source(u), Message: err.Error()})
+			continue
+		}
+		if err := w.checkUntrusted(k); err != nil {
+			problems = append(problems, config.Problem{Source: Source(u), Message: err.Error()})
+			continue
+		}
+		resources = append(resources, k)
+	}
+	configs, more := Configs(resources)
+	problems = append(problems, more...)
+	configs, more = merge(w.Base, configs)
+	problems = append(problems, more...)
+	applied, more := w.Reload(configs)
+	problems = append(problems, more...)
+	log.V(1).Info("Reloaded configuration resources", "resources", len(list.Items), "applied", applied, "problems", len(problems))
+
+	var errs error
+	for i := range list.Items {
+		u := &list.Items[i]
+		inUse := applied && slices.ContainsFunc(configs, func(c config.Config) bool { return c.Source == Source(u) })
+		errs = errors.Join(errs, w.setStatus(ctx, u, inUse, problems))
+	}
+	return list.GetResourceVersion(), errs
+}
+
+// checkUntrusted returns an error if a resource that is not in the trusted namespace has anything but rules and aliases.
+//
+// Stores can read local files and start from the korrel8r credentials, which they would send to any URL.
+// Redactions and tuning affect all users, plugins are loaded from local files.
+func (w *Watcher) checkUntrusted(k *Korrel8rConfig) error {
+	if w.TrustedNamespace == "" || k.Namespace == w.TrustedNamespace {
+		return nil
+	}
+	var what string
+	switch c := &k.Spec; {
+	case len(c.Stores) > 0:
+		what = "stores are"
+	case len(c.Redact) > 0:
+		what = "redact is"
+	case c.Tuning != nil:
+		what = "tuning is"
+	case slices.ContainsFunc(c.Rules, func(r config.Rule) bool { return r.Result.Plugin != nil }):
+		what = "plugin rules are"
+	default:
+		return nil
+	}
+	return fmt.Errorf("untrusted namespace %v: %v not allowed, only rules and aliases", k.Namespace, what)
+}
+
+// SetBase replaces the base configuration and calls [Watcher.Sync] to reload.
+func (w *Watcher) SetBase(ctx context.Context, base config.Configs) error {
+	w.lock.Lock()
+	w.Base = base
+	w.lock.Unlock()
+	_, err := w.Sync(ctx)
+	return err
+}
+
+// merge base configuration with resource configurations.
+// The configuration with a tuning section must be first.
+func merge(base, configs config.Configs) (config.Configs, []config.Problem) {
+	var problems []config.Problem
+	merged := slices.Clone(base)
+	for _, c := range configs {
+		switch {
+		case c.Tuning != nil && len(merged) > 0 && merged[0].Tuning != nil:
+			problems = append(problems, config.Problem{Source: c.Source, Message: "tuning is already set by " + merged[0].Source})
+		case c.Tuning != nil:
+			merged = slices.Insert(merged, 0, c)
+		default:
+			merged = append(merged, c)
+		}
+	}
+	return merged, problems
+}
+
+// setStatus sets the ready condition of a resource.
+func (w *Watcher) setStatus(ctx context.Context, u *unstructured.Unstructured, inUse bool, problems []config.Problem) error {
+	source := Source(u)
+	var messages []string
+	for _, p := range problems {
+		if p.Source == source {
+			messages = append(messages, p.Message)
+		}
+	}
+	cond := metav1.Condition{Type: ConditionReady, ObservedGeneration: u.GetGeneration()}
+	switch {
+	case inUse && len(messages) == 0:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionTrue, ReasonApplied, "Configuration is in use"
+	case inUse:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, ReasonStoreError, strings.Join(messages, "\n")
+	case len(messages) > 0:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, ReasonInvalid, strings.Join(messages, "\n")
+	default:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, ReasonNotApplied, "Configuration is not in use because of problems in other configuration"
+	}
+	status := Status{}
+	if conditions, ok, _ := unstructured.NestedSlice(u.Object, "status", "conditions"); ok {
+		// Ignore errors, existing status will be replaced.
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(map[string]any{"conditions": conditions}, &status)
+	}
+	if !meta.SetStatusCondition(&status.Conditions, cond) {
+		return nil // No change.
+	}
+	patch := client.MergeFrom(u.DeepCopy())
+	s, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(u.Object, s, "status"); err != nil {
+		return err
+	}
+	if err := w.Client.Status().Patch(ctx, u, patch); err != nil {
+		return fmt.Errorf("%v: update status: %w", source, err)
+	}
+	return nil
+}
+
+// loaded returns true if the current generation of u has been loaded.
+func (w *Watcher) loaded(u *unstructured.Unstructured) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	g, ok := w.generations[client.ObjectKeyFromObject(u)]
+	return ok && g == u.GetGeneration()
+}
+
+// watch returns when a resource is added, deleted or its spec changes, or the watch ends.
+func (w *Watcher) watch(ctx context.Context, version string) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(GroupVersionKind.GroupVersion().WithKind(GroupVersionKind.Kind + "List"))
+	wi, err := w.Client.Watch(ctx, list, client.InNamespace(w.Namespace), &client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: version}})
+	if err != nil {
+		return err
+	}
+	defer wi.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-wi.ResultChan():
+			if !ok {
+				return nil // Watch closed, re-sync.
+			}
+			switch e.Type {
+			case watch.Added, watch.Modified:
+				if u, ok := e.Object.(*unstructured.Unstructured); ok && w.loaded(u) {
+					continue // Status update or no change to spec.
+				}
+				return nil
+			case watch.Deleted:
+				return nil
+			case watch.Error:
+				return fmt.Errorf("watch error: %v", e.Object)
+			}
+		}
+	}
+}
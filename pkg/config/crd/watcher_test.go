@@ -0,0 +1,223 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package crd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func resource(t *testing.T, name string, spec map[string]any) *unstructured.Unstructured {
+	t.Helper()
+	u := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	u.SetGroupVersionKind(GroupVersionKind)
+	u.SetNamespace("ns")
+	u.SetName(name)
+	return u
+}
+
+func newClient(objects ...client.Object) client.WithWatch {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(GroupVersionKind, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(GroupVersionKind.GroupVersion().WithKind(GroupVersionKind.Kind+"List"), &unstructured.UnstructuredList{})
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(objects...).Build()
+}
+
+func readyCondition(t *testing.T, c client.Client, name string) *metav1.Condition {
+	t.Helper()
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(GroupVersionKind)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: name}, u))
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	var status Status
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(map[string]any{"conditions": conditions}, &status))
+	return meta.FindStatusCondition(status.Conditions, ConditionReady)
+}
+
+var rule = map[string]any{
+	"name":   "r",
+	"start":  map[string]any{"domain": "mock", "classes": []any{"a"}},
+	"goal":   map[string]any{"domain": "mock", "classes": []any{"b"}},
+	"result": map[string]any{"query": "mock:b:x"},
+}
+
+func TestWatcher_Sync(t *testing.T) {
+	c := newClient(
+		resource(t, "good", map[string]any{"rules": []any{rule}}),
+		resource(t, "store", map[string]any{"stores": []any{map[string]any{"domain": "mock"}}}),
+		resource(t, "include", map[string]any{"include": []any{"x.yaml"}}),
+		resource(t, "unknown", map[string]any{"nonesuch": "x"}),
+	)
+	var got config.Configs
+	w := &Watcher{
+		Client: c,
+		Base:   config.Configs{{Source: "base.yaml"}},
+		Reload: func(configs config.Configs) (bool, []config.Problem) {
+			got = configs
+			return true, []config.Problem{{Source: "korrel8rconfig/ns/store", Message: "mock store: broken"}}
+		},
+	}
+	_, err := w.Sync(context.Background())
+	require.NoError(t, err)
+
+	var sources []string
+	for _, c := range got {
+		sources = append(sources, c.Source)
+	}
+	assert.Equal(t, []string{"base.yaml", "korrel8rconfig/ns/good", "korrel8rconfig/ns/store"}, sources)
+	assert.Equal(t, "r", got[1].Rules[0].Name)
+
+	for _, x := range []struct {
+		name, reason, message string
+		status                metav1.ConditionStatus
+	}{
+		{"good", ReasonApplied, "Configuration is in use", metav1.ConditionTrue},
+		{"store", ReasonStoreError, "mock store: broken", metav1.ConditionFalse},
+		{"include", ReasonInvalid, "include is not allowed in a resource", metav1.ConditionFalse},
+		{"unknown", ReasonInvalid, `unknown field "spec.nonesuch"`, metav1.ConditionFalse},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			cond := readyCondition(t, c, x.name)
+			require.NotNil(t, cond)
+			assert.Equal(t, x.status, cond.Status)
+			assert.Equal(t, x.reason, cond.Reason)
+			assert.Contains(t, cond.Message, x.message)
+		})
+	}
+}
+
+func TestWatcher_SyncNotApplied(t *testing.T) {
+	c := newClient(resource(t, "good", map[string]any{"rules": []any{rule}}), resource(t, "bad", map[string]any{"rules": []any{rule}}))
+	w := &Watcher{
+		Client: c,
+		Reload: func(configs config.Configs) (bool, []config.Problem) {
+			return false, []config.Problem{{Source: "korrel8rconfig/ns/bad", Message: "Duplicate rule name: r"}}
+		},
+	}
+	_, err := w.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ReasonNotApplied, readyCondition(t, c, "good").Reason)
+	assert.Equal(t, ReasonInvalid, readyCondition(t, c, "bad").Reason)
+	assert.Equal(t, "Duplicate rule name: r", readyCondition(t, c, "bad").Message)
+}
+
+func TestWatcher_SyncUntrusted(t *testing.T) {
+	c := newClient(
+		resource(t, "rules", map[string]any{"rules": []any{rule}, "aliases": []any{map[string]any{"name": "x", "domain": "mock", "classes": []any{"a"}}}}),
+		resource(t, "stores", map[string]any{"stores": []any{map[string]any{"domain": "mock", "tokenFile": "/var/run/secrets/token"}}}),
+		resource(t, "redact", map[string]any{"redact": []any{map[string]any{"domain": "mock", "replace": true}}}),
+		resource(t, "tuning", map[string]any{"tuning": map[string]any{}}),
+		resource(t, "plugin", map[string]any{"rules": []any{map[string]any{
+			"name": "p", "start": map[string]any{"domain": "mock"}, "goal": map[string]any{"domain": "mock"},
+			"result": map[string]any{"plugin": map[string]any{"module": "/tmp/x.wasm"}},
+		}}}),
+	)
+	var got config.Configs
+	w := &Watcher{
+		Client:           c,
+		TrustedNamespace: "korrel8r",
+		Reload:           func(configs config.Configs) (bool, []config.Problem) { got = configs; return true, nil },
+	}
+	_, err := w.Sync(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "korrel8rconfig/ns/rules", got[0].Source)
+	for name, what := range map[string]string{"stores": "stores are", "redact": "redact is", "tuning": "tuning is", "plugin": "plugin rules are"} {
+		assert.Equal(t, ReasonInvalid, readyCondition(t, c, name).Reason, name)
+		assert.Equal(t, "untrusted namespace ns: "+what+" not allowed, only rules and aliases", readyCondition(t, c, name).Message)
+	}
+
+	w.TrustedNamespace = "ns"
+	_, err = w.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, got, 5)
+	assert.Equal(t, ReasonApplied, readyCondition(t, c, "stores").Reason)
+}
+
+// watchingClient signals when a watch starts, the fake client does not replay events from a resource version.
+type watchingClient struct {
+	client.WithWatch
+	watching chan struct{}
+}
+
+func (c watchingClient) Watch(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	wi, err := c.WithWatch.Watch(ctx, list, opts...)
+	c.watching <- struct{}{}
+	return wi, err
+}
+
+func receive[T any](t *testing.T, ch <-chan T) (v T) {
+	t.Helper()
+	select {
+	case v = <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	}
+	return v
+}
+
+func TestWatcher_Run(t *testing.T) {
+	c := watchingClient{WithWatch: newClient(resource(t, "a", map[string]any{"rules": []any{rule}})), watching: make(chan struct{}, 10)}
+	reloaded := make(chan int, 10)
+	w := &Watcher{
+		Client: c,
+		Reload: func(configs config.Configs) (bool, []config.Problem) {
+			reloaded <- len(configs)
+			return true, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = w.Run(ctx) }()
+	wait := func() int {
+		t.Helper()
+		n := receive(t, reloaded)
+		receive(t, c.watching) // Don't change resources until the watch has started.
+		return n
+	}
+	assert.Equal(t, 1, wait())
+	require.NoError(t, c.Create(ctx, resource(t, "b", map[string]any{})))
+	assert.Equal(t, 2, wait())
+	require.NoError(t, c.Delete(ctx, resource(t, "a", nil)))
+	assert.Equal(t, 1, wait())
+}
+
+func TestMerge(t *testing.T) {
+	tuning := &config.Tuning{}
+	merged, problems := merge(
+		config.Configs{{Source: "base"}},
+		config.Configs{{Source: "a"}, {Source: "b", Tuning: tuning}, {Source: "c", Tuning: tuning}})
+	var sources []string
+	for _, c := range merged {
+		sources = append(sources, c.Source)
+	}
+	assert.Equal(t, []string{"b", "base", "a"}, sources)
+	assert.Equal(t, []config.Problem{{Source: "c", Message: "tuning is already set by b"}}, problems)
+}
+
+func TestWatcher_SetBase(t *testing.T) {
+	var got config.Configs
+	w := &Watcher{
+		Client: newClient(resource(t, "a", map[string]any{"rules": []any{rule}})),
+		Base:   config.Configs{{Source: "base.yaml"}},
+		Reload: func(configs config.Configs) (bool, []config.Problem) { got = configs; return true, nil },
+	}
+	require.NoError(t, w.SetBase(context.Background(), config.Configs{{Source: "base.yaml"}, {Source: "discovery"}}))
+	var sources []string
+	for _, c := range got {
+		sources = append(sources, c.Source)
+	}
+	assert.Equal(t, []string{"base.yaml", "discovery", "korrel8rconfig/ns/a"}, sources)
+}
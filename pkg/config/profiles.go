@@ -0,0 +1,143 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ProfileSource returns the Source for configuration added by a profile.
+func ProfileSource(source, profile string) string {
+	return fmt.Sprintf("%v [profile %v]", source, profile)
+}
+
+// applyProfile applies all profiles with the given name, and removes all profiles.
+func applyProfile(configs Configs, name string) (Configs, error) {
+	found := false
+	n := len(configs) // Don't apply profiles from configs added by profiles.
+	for i := 0; i < n; i++ {
+		source, profiles := configs[i].Source, configs[i].Profiles
+		configs[i].Profiles = nil
+		for _, p := range profiles {
+			if name == "" || p.Name != name {
+				continue
+			}
+			found = true
+			var err error
+			if configs, err = p.apply(configs, ProfileSource(source, name)); err != nil {
+				return nil, fmt.Errorf("%v: profile %v: %w", source, name, err)
+			}
+		}
+	}
+	if name != "" && !found {
+		return nil, fmt.Errorf("profile not found: %v", name)
+	}
+	return configs, nil
+}
+
+// apply the profile to configs, adding a new Config for modified rules and stores.
+func (p *Profile) apply(configs Configs, source string) (Configs, error) {
+	added := Config{Source: source}
+	for _, name := range p.RemoveRules {
+		if !removeRule(configs, name) {
+			return nil, fmt.Errorf("rule to remove not found: %v", name)
+		}
+	}
+	for _, r := range p.Rules {
+		removeRule(configs, r.Name) // Replace if present.
+		added.Rules = append(added.Rules, r)
+	}
+	for _, patch := range p.Stores {
+		if patch[StoreKeyDomain] == "" {
+			return nil, fmt.Errorf("store has no domain: %v", patch)
+		}
+		patched := false
+		for i, s := range added.Stores { // Stores patched by an earlier entry in this profile.
+			if sameStore(s, patch) {
+				added.Stores[i] = patchStore(s, patch)
+				patched = true
+			}
+		}
+		for i := range configs {
+			c := &configs[i]
+			c.Stores = slices.DeleteFunc(c.Stores, func(s Store) bool {
+				if !sameStore(s, patch) {
+					return false
+				}
+				added.Stores = append(added.Stores, patchStore(s, patch))
+				patched = true
+				return true
+			})
+		}
+		if !patched {
+			added.Stores = append(added.Stores, patchStore(nil, patch))
+		}
+	}
+	if p.Tuning != nil {
+		if len(configs) == 0 {
+			return nil, fmt.Errorf("no configuration for tuning")
+		}
+		configs[0].Tuning = patchTuning(configs[0].Tuning, p.Tuning)
+	}
+	if len(added.Rules) > 0 || len(added.Stores) > 0 {
+		configs = append(configs, added)
+	}
+	return configs, nil
+}
+
+// sameStore returns true if a store patch applies to store s: they have the same domain and cluster.
+func sameStore(s, patch Store) bool {
+	return s[StoreKeyDomain] == patch[StoreKeyDomain] && s[StoreKeyCluster] == patch[StoreKeyCluster]
+}
+
+// removeRule removes the named rule from configs, returns false if not found.
+func removeRule(configs Configs, name string) bool {
+	removed := false
+	for i := range configs {
+		c := &configs[i]
+		c.Rules = slices.DeleteFunc(c.Rules, func(r Rule) bool {
+			removed = removed || r.Name == name
+			return r.Name == name
+		})
+	}
+	return removed
+}
+
+// patchStore returns a copy of s with keys from patch, empty values in patch remove keys.
+func patchStore(s, patch Store) Store {
+	s = maps.Clone(s)
+	if s == nil {
+		s = Store{}
+	}
+	for k, v := range patch {
+		if v == "" {
+			delete(s, k)
+		} else {
+			s[k] = v
+		}
+	}
+	return s
+}
+
+// patchTuning returns a copy of t with non-zero values from patch.
+func patchTuning(t, patch *Tuning) *Tuning {
+	result := Tuning{}
+	if t != nil {
+		result = *t
+	}
+	if patch.RequestTimeout.Duration != 0 {
+		result.RequestTimeout = patch.RequestTimeout
+	}
+	if len(patch.Domains) > 0 {
+		result.Domains = maps.Clone(result.Domains)
+		if result.Domains == nil {
+			result.Domains = map[string]StoreTuning{}
+		}
+		for d, st := range patch.Domains {
+			result.Domains[d] = st.Merge(result.Domains[d])
+		}
+	}
+	return &result
+}
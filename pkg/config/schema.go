@@ -0,0 +1,154 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SchemaURI is the JSON Schema dialect used by [Schema].
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema for korrel8r configuration files.
+//
+// storeKeys maps domain names to the store keys accepted by that domain, in addition to the
+// StoreKey constants in this package that are accepted by all stores.
+// A domain with a nil key list accepts any keys.
+func Schema(storeKeys map[string][]string) map[string]any {
+	s := schema{defs: map[string]any{}}
+	s.defs["Store"] = storeSchema(storeKeys)
+	root := s.typeSchema(reflect.TypeOf(Config{}))
+	return map[string]any{
+		"$schema": SchemaURI,
+		"title":   "Korrel8r configuration",
+		"$ref":    root["$ref"],
+		"$defs":   s.defs,
+	}
+}
+
+// CommonStoreKeys are the store keys that are accepted by all stores.
+var CommonStoreKeys = []string{
+	StoreKeyDomain, StoreKeyError, StoreKeyErrorCount, StoreKeyMock, StoreKeyCA, StoreKeyDiscovered, StoreKeyCluster,
+	StoreKeyLimit, StoreKeyTimeout, StoreKeyLookback, StoreKeyMaxConcurrent, StoreKeyMaxResponseSize,
+}
+
+// MatchStoreKey returns true if key is in keys.
+// A key ending in "*" in keys matches any key with the same prefix.
+func MatchStoreKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key || (strings.HasSuffix(k, "*") && strings.HasPrefix(key, k[:len(k)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+type schema struct{ defs map[string]any }
+
+var (
+	storeType    = reflect.TypeOf(Store{})
+	durationType = reflect.TypeOf(Duration{})
+)
+
+func (s *schema) typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case storeType:
+		return map[string]any{"$ref": "#/$defs/Store"}
+	case durationType:
+		return map[string]any{"type": []string{"string", "number"}, "description": "Duration string like '10s' or number of seconds"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": s.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.typeSchema(t.Elem())}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := s.defs[t.Name()]; ok {
+			return ref
+		}
+		s.defs[t.Name()] = nil // Placeholder to stop recursion.
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = s.typeSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		def := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		s.defs[t.Name()] = def
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+// storeSchema allows only known store keys for each domain.
+func storeSchema(storeKeys map[string][]string) map[string]any {
+	domains := make([]string, 0, len(storeKeys))
+	for d := range storeKeys {
+		domains = append(domains, d)
+	}
+	slices.Sort(domains)
+	var cases []any
+	for _, d := range domains {
+		keys := storeKeys[d]
+		if keys == nil {
+			continue // Any keys allowed.
+		}
+		properties, patterns := map[string]any{}, map[string]any{}
+		for _, k := range append(slices.Clone(CommonStoreKeys), keys...) {
+			if prefix, ok := strings.CutSuffix(k, "*"); ok {
+				patterns["^"+regexp.QuoteMeta(prefix)] = map[string]any{"type": "string"}
+			} else {
+				properties[k] = map[string]any{"type": "string"}
+			}
+		}
+		then := map[string]any{"properties": properties, "additionalProperties": false}
+		if len(patterns) > 0 {
+			then["patternProperties"] = patterns
+		}
+		cases = append(cases, map[string]any{
+			"if":   map[string]any{"properties": map[string]any{StoreKeyDomain: map[string]any{"const": d}}},
+			"then": then,
+		})
+	}
+	domain := map[string]any{"type": "string"}
+	if len(domains) > 0 {
+		domain["enum"] = domains
+	}
+	store := map[string]any{
+		"type":                 "object",
+		"description":          "Store is a map of name:value attributes used to connect to a store.",
+		"properties":           map[string]any{StoreKeyDomain: domain},
+		"required":             []string{StoreKeyDomain},
+		"additionalProperties": map[string]any{"type": "string"},
+	}
+	if len(cases) > 0 {
+		store["allOf"] = cases
+	}
+	return store
+}
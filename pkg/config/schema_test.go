@@ -0,0 +1,40 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema(map[string][]string{"foo": {"url", "header.*"}, "bar": nil})
+	assert.Equal(t, SchemaURI, s["$schema"])
+	assert.Equal(t, "#/$defs/Config", s["$ref"])
+	defs := s["$defs"].(map[string]any)
+
+	rule := defs["Rule"].(map[string]any)
+	assert.Equal(t, []string{"start", "goal"}, rule["required"])
+	assert.Equal(t, map[string]any{"$ref": "#/$defs/WhenSpec"}, rule["properties"].(map[string]any)["when"])
+	assert.Equal(t, false, rule["additionalProperties"])
+
+	store := defs["Store"].(map[string]any)
+	assert.Equal(t, []string{"bar", "foo"}, store["properties"].(map[string]any)["domain"].(map[string]any)["enum"])
+	cases := store["allOf"].([]any)
+	if assert.Len(t, cases, 1) { // No case for bar, it accepts any keys.
+		then := cases[0].(map[string]any)["then"].(map[string]any)
+		assert.Contains(t, then["properties"], "url")
+		assert.Contains(t, then["properties"], StoreKeyCA)
+		assert.NotContains(t, then["properties"], "other")
+		assert.Equal(t, map[string]any{`^header\.`: map[string]any{"type": "string"}}, then["patternProperties"])
+	}
+}
+
+func TestMatchStoreKey(t *testing.T) {
+	keys := []string{"url", "header.*"}
+	assert.True(t, MatchStoreKey(keys, "url"))
+	assert.True(t, MatchStoreKey(keys, "header.X-Scope-OrgID"))
+	assert.False(t, MatchStoreKey(keys, "header"))
+	assert.False(t, MatchStoreKey(keys, "urls"))
+}
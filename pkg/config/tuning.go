@@ -0,0 +1,64 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Tuning returns the store tuning set by store keys, see [StoreTuning].
+func (s Store) Tuning() (t StoreTuning, err error) {
+	parseInt := func(key string, bits int) int64 {
+		v, ok := s[key]
+		if !ok || err != nil {
+			return 0
+		}
+		var n int64
+		if n, err = strconv.ParseInt(v, 10, bits); err == nil && n < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+		if err != nil {
+			err = fmt.Errorf("%v: %w", key, err)
+		}
+		return n
+	}
+	parseDuration := func(key string) Duration {
+		v, ok := s[key]
+		if !ok || err != nil {
+			return Duration{}
+		}
+		var d time.Duration
+		if d, err = time.ParseDuration(v); err != nil {
+			err = fmt.Errorf("%v: %w", key, err)
+		}
+		return Duration{Duration: d}
+	}
+	t.Limit = int(parseInt(StoreKeyLimit, 0))
+	t.Timeout = parseDuration(StoreKeyTimeout)
+	t.Lookback = parseDuration(StoreKeyLookback)
+	t.MaxConcurrent = int(parseInt(StoreKeyMaxConcurrent, 0))
+	t.MaxResponseSize = parseInt(StoreKeyMaxResponseSize, 64)
+	return t, err
+}
+
+// Merge returns t with zero values replaced by values from defaults.
+func (t StoreTuning) Merge(defaults StoreTuning) StoreTuning {
+	if t.Limit == 0 {
+		t.Limit = defaults.Limit
+	}
+	if t.Timeout.Duration == 0 {
+		t.Timeout = defaults.Timeout
+	}
+	if t.Lookback.Duration == 0 {
+		t.Lookback = defaults.Lookback
+	}
+	if t.MaxConcurrent == 0 {
+		t.MaxConcurrent = defaults.MaxConcurrent
+	}
+	if t.MaxResponseSize == 0 {
+		t.MaxResponseSize = defaults.MaxResponseSize
+	}
+	return t
+}
@@ -0,0 +1,33 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Tuning(t *testing.T) {
+	got, err := Store{
+		StoreKeyDomain:          "x",
+		StoreKeyLimit:           "10",
+		StoreKeyLookback:        "5m",
+		StoreKeyMaxResponseSize: "1000000",
+	}.Tuning()
+	require.NoError(t, err)
+	assert.Equal(t, StoreTuning{Limit: 10, Lookback: Duration{5 * time.Minute}, MaxResponseSize: 1000000}, got)
+
+	_, err = Store{StoreKeyTimeout: "soon"}.Tuning()
+	assert.EqualError(t, err, `timeout: time: invalid duration "soon"`)
+	_, err = Store{StoreKeyMaxConcurrent: "-1"}.Tuning()
+	assert.EqualError(t, err, `maxConcurrent: must not be negative`)
+}
+
+func TestStoreTuning_Merge(t *testing.T) {
+	store := StoreTuning{Limit: 10}
+	domain := StoreTuning{Limit: 100, Timeout: Duration{time.Second}, MaxConcurrent: 4}
+	assert.Equal(t, StoreTuning{Limit: 10, Timeout: Duration{time.Second}, MaxConcurrent: 4}, store.Merge(domain))
+}
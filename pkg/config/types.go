@@ -14,14 +14,23 @@ type Config struct {
 	// Stores is a list of store configurations.
 	Stores []Store `json:"stores,omitempty"`
 
-	// Include lists additional configuration files or URLs to include.
+	// Redact removes or masks sensitive fields of objects returned by the REST API or printed by the command line.
+	Redact []Redaction `json:"redact,omitempty"`
+
+	// Include lists additional configuration files or URLs to include, file paths can be glob patterns.
 	Include []string `json:"include,omitempty"`
 
 	// Tuning section has limits and optimizations.
 	Tuning *Tuning `json:"tuning,omitempty"`
 
+	// Profiles are named overlays that modify the configuration when selected, see [LoadProfile].
+	Profiles []Profile `json:"profiles,omitempty"`
+
 	// Soure of configuration, file or URL.
 	Source string `json:"-"`
+
+	// Lines are the line numbers of items in Source, recorded by [Load]. Nil if not known.
+	Lines *Lines `json:"-"`
 }
 
 // Configs is a list of configs from different sources.
@@ -38,6 +47,34 @@ const (
 	StoreKeyErrorCount = "errorCount"           // Count of errors on a store.
 	StoreKeyMock       = "mockData"             // Store loads mock data from a file.
 	StoreKeyCA         = "certificateAuthority" // Path to CA certificate.
+	StoreKeyDiscovered = "discovered"           // Cluster resource the store was discovered from, set by discovery.
+	StoreKeyCluster    = "cluster"              // Name of the cluster the store belongs to, for multi-cluster configurations.
+)
+
+// StoreKeyCache is a comma-separated list of classes for a store to cache, for domains that support caching.
+// Aliases in the list are expanded by [Expand].
+const StoreKeyCache = "cache"
+
+// Store keys for tuning a single store, see [StoreTuning].
+const (
+	StoreKeyLimit           = "limit"
+	StoreKeyTimeout         = "timeout"
+	StoreKeyLookback        = "lookback"
+	StoreKeyMaxConcurrent   = "maxConcurrent"
+	StoreKeyMaxResponseSize = "maxResponseSize"
+)
+
+// Store keys for authentication and connection options, used by stores that connect over HTTP.
+const (
+	StoreKeyToken              = "token"              // Bearer token.
+	StoreKeyTokenFile          = "tokenFile"          // Path to bearer token file, re-read periodically.
+	StoreKeyUsername           = "username"           // User name for basic authentication.
+	StoreKeyPassword           = "password"           // Password for basic authentication.
+	StoreKeyClientCert         = "clientCertificate"  // Path to client certificate for mutual TLS.
+	StoreKeyClientKey          = "clientKey"          // Path to client key for mutual TLS.
+	StoreKeyProxy              = "proxy"              // HTTP proxy URL.
+	StoreKeyInsecureSkipVerify = "insecureSkipVerify" // "true" to skip server certificate verification, for testing only.
+	StoreKeyHeaderPrefix       = "header."            // Prefix for static request headers, e.g. "header.X-Scope-OrgID".
 )
 
 // Rule configures a template rule.
@@ -45,6 +82,7 @@ const (
 // The rule template is applied to a instance of the start object.
 // It should generate one of the following:
 // - a goal query string of the form DOAMAIN:CLASS:QUERY_DATA.
+// - a list of goal query strings, each starting on a new line. Queries can be for different goal classes.
 // - a blank (whitespace-only) string if the rule does not apply to the given object.
 // - an error if something unexpected goes wrong.
 //
@@ -62,10 +100,28 @@ type Rule struct {
 	// Goal specifies the set of classes that this rule can produce.
 	Goal ClassSpec `json:"goal"`
 
+	// Priority orders rules with the same start and goal classes, higher priority rules are followed first.
+	// Default priority is 0.
+	Priority int `json:"priority,omitempty"`
+
+	// Exclusive rules suppress lower priority rules with the same start and goal classes.
+	// If an exclusive rule produces results, lower priority rules are not followed.
+	// This is useful for fallback rules that are only needed when primary data is missing.
+	Exclusive bool `json:"exclusive,omitempty"`
+
+	// When contains optional preconditions that a start object must satisfy for the rule to apply.
+	When *WhenSpec `json:"when,omitempty"`
+
+	// Bidirectional declares fields of the start and goal classes that have equivalent values,
+	// instead of a Result. Two mapping rules are generated, one in each direction,
+	// named NAME/forward (start to goal) and NAME/inverse (goal to start).
+	// Start and goal must each be a single class. See [ResultSpec.Mapping] for fields and parameters.
+	Bidirectional []Equivalence `json:"bidirectional,omitempty"`
+
 	// TemplateResult contains templates to generate the result of applying this rule.
 	// Each template is applied to an object from one of the `start` classes.
 	// If any template yields a blank string or an error, the rule does not apply.
-	Result ResultSpec `json:"result"`
+	Result ResultSpec `json:"result,omitempty"`
 }
 
 // ClassSpec specifies one or more classes.
@@ -78,10 +134,131 @@ type ClassSpec struct {
 	Classes []string `json:"classes,omitempty"`
 }
 
+// WhenSpec contains preconditions for a rule.
+//
+// Conditions are tested on a start object before the result template is executed.
+// The rule applies only if all conditions are true.
+// Rules that don't apply are skipped cheaply, with a clear reason in the logs.
+type WhenSpec struct {
+	// Classes restricts the rule to start objects in one of these classes from the start domain.
+	// Aliases are allowed.
+	Classes []string `json:"classes,omitempty"`
+
+	// Labels the start object must have.
+	// An empty value means the label must be present with any value.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Fields maps template field expressions (e.g. `.Spec.NodeName`) to regular expressions.
+	// The field value must be non-empty and match the regular expression.
+	// An empty regular expression matches any non-empty value.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
 // ResultSpec contains templates to generate a result.
 type ResultSpec struct {
 	// Query template generates a query object suitable for the goal store.
-	Query string `json:"query"`
+	Query string `json:"query,omitempty"`
+
+	// Plugin is a WebAssembly module to generate queries, instead of the Query template.
+	Plugin *PluginSpec `json:"plugin,omitempty"`
+
+	// Mapping maps goal query parameters to field paths in the start object, instead of the Query template.
+	// The goal domain builds the query, so query syntax and escaping are always correct.
+	// The rule must have a single goal class, and does not apply if any field is empty.
+	//
+	// Field paths are field names and quoted map keys, for example: `.Labels.namespace`, `.Attributes["k8s.pod.name"]`.
+	// A path ending in `?` is optional, see [OptionalSuffix]. The rule does not apply if all fields are empty.
+	// Parameter names depend on the goal domain:
+	//   - k8s: `namespace`, `name`, `labels.KEY`, `fields.KEY`
+	//   - log: LogQL stream label names.
+	//   - trace: TraceQL attribute names, for example `resource.k8s.pod.name`.
+	//   - alert: alert label names.
+	Mapping map[string]string `json:"mapping,omitempty"`
+}
+
+// PluginSpec configures a WebAssembly rule plugin.
+//
+// The module reads a JSON-encoded start object from standard input and writes goal queries
+// to standard output, one per line beginning with the goal domain name.
+// Plugins run in a sandbox without access to the host file system or network.
+type PluginSpec struct {
+	// Module is the path to a WebAssembly module file.
+	// A relative path is relative to the location of the configuration file containing it.
+	Module string `json:"module"`
+
+	// MemoryLimit is the maximum memory for the module in megabytes, default 16.
+	MemoryLimit int `json:"memoryLimit,omitempty"`
+
+	// Timeout is the maximum time for the module to process one start object, default 1s.
+	Timeout Duration `json:"timeout,omitempty" swaggertype:"string"`
+}
+
+// Equivalence declares that a field of a start object and a field of a goal object have the same value.
+type Equivalence struct {
+	Start FieldSpec `json:"start"`
+	Goal  FieldSpec `json:"goal"`
+}
+
+// FieldSpec identifies a field of an object, and the query parameter that selects objects by that field.
+type FieldSpec struct {
+	// Field path in objects of the class, for example `.Namespace` or `.Attributes["k8s.namespace.name"]`
+	// A path ending in `?` is optional in the rule that starts from this class, see [OptionalSuffix].
+	Field string `json:"field"`
+	// Param is the query parameter for the field, for example `namespace` or `resource.k8s.namespace.name`.
+	Param string `json:"param"`
+}
+
+// OptionalSuffix on a mapping field path marks the field as optional: if it is empty,
+// the parameter is left out of the query instead of the rule not applying.
+const OptionalSuffix = "?"
+
+// Suffixes for the names of rules generated from a bidirectional rule.
+const (
+	ForwardSuffix = "/forward"
+	InverseSuffix = "/inverse"
+)
+
+// Profile is a named overlay that modifies rules, stores and tuning when it is selected.
+type Profile struct {
+	// Name of the profile.
+	Name string `json:"name"`
+
+	// Rules to add, a rule replaces an existing rule with the same name.
+	Rules []Rule `json:"rules,omitempty"`
+
+	// RemoveRules lists names of rules to remove.
+	RemoveRules []string `json:"removeRules,omitempty"`
+
+	// Stores to patch. Keys are merged into existing stores with the same domain and cluster, an empty value removes a key.
+	// The store is added if there is no such store.
+	Stores []Store `json:"stores,omitempty"`
+
+	// Tuning to patch, non-zero values replace the top-level tuning values.
+	Tuning *Tuning `json:"tuning,omitempty"`
+}
+
+// Redaction removes or masks sensitive fields of objects before they leave the engine,
+// through the REST API or the command line. Rules see objects before redaction.
+// Redactions for the same class are combined.
+type Redaction struct {
+	// Domain of the classes to redact.
+	Domain string `json:"domain"`
+
+	// Classes to redact, aliases are allowed. If absent, all classes in the domain are redacted.
+	Classes []string `json:"classes,omitempty"`
+
+	// Remove lists field paths to remove, using the JSON field names of objects.
+	// Path elements are separated by '.', `[]` selects every element of a list,
+	// and `["KEY"]` selects a map key that contains '.' or '['.
+	// For example: `data`, `spec.containers[].env[].value`, `metadata.annotations["example.com/secret"]`.
+	Remove []string `json:"remove,omitempty"`
+
+	// Mask lists regular expressions, text that matches in any string value is replaced by `<redacted>`.
+	Mask []string `json:"mask,omitempty"`
+
+	// Replace earlier redactions for the same domain or classes, including built-in defaults,
+	// instead of adding to them.
+	Replace bool `json:"replace,omitempty"`
 }
 
 // Class defines a shortcut name for a set of existing classes.
@@ -98,5 +275,24 @@ type Class struct {
 type Tuning struct {
 	// RequestTimeout cancel requests if they last longer than this timeout.
 	// Cancelling a correlation operation may return an error or a partial result (HTTP 206).
-	RequestTimeout Duration `json:"requestTimeout,omitempty"`
+	RequestTimeout Duration `json:"requestTimeout,omitempty" swaggertype:"string"`
+
+	// Domains maps domain names to tuning for all stores of the domain.
+	// Store keys with the same names override the domain tuning for a single store.
+	Domains map[string]StoreTuning `json:"domains,omitempty"`
+}
+
+// StoreTuning sets defaults and limits for queries to a store.
+// Zero values are not set, global defaults are used.
+type StoreTuning struct {
+	// Limit is the default maximum number of objects returned by a query.
+	Limit int `json:"limit,omitempty"`
+	// Timeout is the default timeout for a query.
+	Timeout Duration `json:"timeout,omitempty" swaggertype:"string"`
+	// Lookback is the default duration of the query time interval, ending at the constraint end time.
+	Lookback Duration `json:"lookback,omitempty" swaggertype:"string"`
+	// MaxConcurrent is the maximum number of concurrent queries to a store, default 1.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// MaxResponseSize is the maximum size of a response in bytes, for stores that connect over HTTP.
+	MaxResponseSize int64 `json:"maxResponseSize,omitempty"`
 }
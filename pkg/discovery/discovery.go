@@ -0,0 +1,262 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+// Package discovery finds observability stores in an OpenShift cluster.
+//
+// Stores are discovered from resources installed by the observability operators:
+//
+//   - LokiStack resources: `log` stores, or `netflow` stores if the LokiStack is used by a netobserv FlowCollector.
+//   - TempoStack resources: a `trace` store for each tenant.
+//   - The thanos-querier and alertmanager-main services in openshift-monitoring: `metric` and `alert` stores.
+//
+// Stores connect to Services in the cluster, or to Routes if [Options.Routes] is set.
+// Discovered stores have a [config.StoreKeyDiscovered] key naming the resource they were discovered from.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/logging"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var log = logging.Log()
+
+// Source is the configuration source name for discovered stores.
+const Source = "discovery"
+
+// ServiceCAFile is the service CA certificate mounted in an OpenShift pod, used to verify Service certificates.
+const ServiceCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+
+// Resource kinds used for discovery.
+var (
+	LokiStack     = schema.GroupVersionKind{Group: "loki.grafana.com", Version: "v1", Kind: "LokiStack"}
+	TempoStack    = schema.GroupVersionKind{Group: "tempo.grafana.com", Version: "v1alpha1", Kind: "TempoStack"}
+	FlowCollector = schema.GroupVersionKind{Group: "flows.netobserv.io", Version: "v1beta2", Kind: "FlowCollector"}
+)
+
+// Names of the cluster monitoring Services and Routes.
+const (
+	MonitoringNamespace = "openshift-monitoring"
+	ThanosQuerier       = "thanos-querier"
+	Alertmanager        = "alertmanager-main"
+)
+
+// Options for discovery.
+type Options struct {
+	// Routes uses Route hosts for store URLs, for running outside the cluster.
+	// Otherwise use Service URLs, for running in the cluster.
+	Routes bool
+	// CertificateAuthority for Service URLs, default is [ServiceCAFile]. Not used with Routes.
+	CertificateAuthority string
+}
+
+// Discover returns stores found in the cluster.
+// Operators and resources that are not installed are skipped.
+// If some discovery fails, the stores that were found are returned with the error.
+func Discover(ctx context.Context, c client.Reader, opts Options) ([]config.Store, error) {
+	found, errs := discover(ctx, c, opts)
+	return slices.Concat(found...), errors.Join(errs...)
+}
+
+// discover runs each discoverer in turn, returns the stores found and the error from each.
+func discover(ctx context.Context, c client.Reader, opts Options) (found [][]config.Store, errs []error) {
+	d := &discoverer{Reader: c, Options: opts}
+	if d.CertificateAuthority == "" {
+		d.CertificateAuthority = ServiceCAFile
+	}
+	for _, f := range []func(context.Context) error{d.monitoring, d.loki, d.tempo} {
+		d.stores = nil
+		errs = append(errs, f(ctx))
+		found = append(found, d.stores)
+	}
+	return found, errs
+}
+
+type discoverer struct {
+	client.Reader
+	Options
+	stores []config.Store
+}
+
+// add a store discovered from a resource.
+func (d *discoverer) add(domain, from string, s config.Store) {
+	s[config.StoreKeyDomain] = domain
+	s[config.StoreKeyDiscovered] = from
+	if !d.Routes {
+		s[config.StoreKeyCA] = d.CertificateAuthority
+	}
+	log.V(2).Info("Discovered store", "domain", domain, "from", from)
+	d.stores = append(d.stores, s)
+}
+
+func (d *discoverer) monitoring(ctx context.Context) error {
+	thanos, err := d.url(ctx, MonitoringNamespace, ThanosQuerier, ThanosQuerier, "web")
+	if err != nil || thanos == "" {
+		return err
+	}
+	d.add("metric", d.from(MonitoringNamespace, ThanosQuerier), config.Store{"metric": thanos})
+	alertmanager, err := d.url(ctx, MonitoringNamespace, Alertmanager, Alertmanager, "web")
+	if err != nil || alertmanager == "" {
+		return err
+	}
+	d.add("alert", d.from(MonitoringNamespace, Alertmanager), config.Store{"metrics": thanos, "alertmanager": alertmanager})
+	return nil
+}
+
+func (d *discoverer) loki(ctx context.Context) error {
+	stacks, err := d.list(ctx, LokiStack)
+	if err != nil {
+		return err
+	}
+	netflow, err := d.netobservStack(ctx)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, ls := range stacks {
+		// The Loki operator creates a gateway Service, and a Route with the same name as the LokiStack.
+		u, err := d.url(ctx, ls.GetNamespace(), ls.GetName()+"-gateway-http", ls.GetName(), "gateway-http")
+		if err != nil || u == "" {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		domain := "log"
+		if client.ObjectKeyFromObject(&ls) == netflow {
+			domain = "netflow"
+		}
+		d.add(domain, resourceName(LokiStack, &ls), config.Store{"lokiStack": u})
+	}
+	return errs
+}
+
+// netobservStack returns the LokiStack used by the netobserv FlowCollector, if there is one.
+func (d *discoverer) netobservStack(ctx context.Context) (client.ObjectKey, error) {
+	collectors, err := d.list(ctx, FlowCollector)
+	if err != nil || len(collectors) == 0 {
+		return client.ObjectKey{}, err
+	}
+	fc := collectors[0].Object
+	key := client.ObjectKey{}
+	key.Name, _, _ = unstructured.NestedString(fc, "spec", "loki", "lokiStack", "name")
+	key.Namespace, _, _ = unstructured.NestedString(fc, "spec", "loki", "lokiStack", "namespace")
+	if key.Namespace == "" {
+		key.Namespace, _, _ = unstructured.NestedString(fc, "spec", "namespace")
+	}
+	return key, nil
+}
+
+func (d *discoverer) tempo(ctx context.Context) error {
+	stacks, err := d.list(ctx, TempoStack)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, ts := range stacks {
+		// The Tempo operator creates a gateway Service and Route if the gateway is enabled.
+		name := "tempo-" + ts.GetName() + "-gateway"
+		u, err := d.url(ctx, ts.GetNamespace(), name, name, "public")
+		if err != nil || u == "" {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		authentication, _, _ := unstructured.NestedSlice(ts.Object, "spec", "tenants", "authentication")
+		for _, a := range authentication {
+			m, _ := a.(map[string]any)
+			tenant, _, _ := unstructured.NestedString(m, "tenantName")
+			if tenant == "" {
+				continue
+			}
+			d.add("trace", resourceName(TempoStack, &ts), config.Store{
+				"tempoStack": u + "/api/traces/v1/" + url.PathEscape(tenant) + "/tempo/api/search",
+				"tenant":     tenant,
+			})
+		}
+	}
+	return errs
+}
+
+// list resources of a kind in all namespaces, returns nothing if the kind is not installed.
+func (d *discoverer) list(ctx context.Context, gvk schema.GroupVersionKind) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := d.List(ctx, list); err != nil {
+		if notInstalled(err) {
+			log.V(2).Info("Discovery: kind not installed", "kind", gvk.Kind)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("discover %v: %w", gvk.Kind, err)
+	}
+	slices.SortFunc(list.Items, func(a, b unstructured.Unstructured) int {
+		return strings.Compare(a.GetNamespace()+"/"+a.GetName(), b.GetNamespace()+"/"+b.GetName())
+	})
+	return list.Items, nil
+}
+
+// url returns the base URL for a Service or a Route, or "" if it does not exist.
+// The service port is the first port called portName, or the first port if there is none.
+func (d *discoverer) url(ctx context.Context, namespace, service, route, portName string) (string, error) {
+	if d.Routes {
+		r := &routev1.Route{}
+		if err := d.Get(ctx, client.ObjectKey{Namespace: namespace, Name: route}, r); err != nil {
+			return "", ignoreMissing(err, "Route", namespace, route)
+		}
+		scheme := "https"
+		if r.Spec.TLS == nil {
+			scheme = "http"
+		}
+		return (&url.URL{Scheme: scheme, Host: r.Spec.Host, Path: r.Spec.Path}).String(), nil
+	}
+	s := &corev1.Service{}
+	if err := d.Get(ctx, client.ObjectKey{Namespace: namespace, Name: service}, s); err != nil {
+		return "", ignoreMissing(err, "Service", namespace, service)
+	}
+	if len(s.Spec.Ports) == 0 {
+		return "", nil
+	}
+	port := s.Spec.Ports[0].Port
+	for _, p := range s.Spec.Ports {
+		if p.Name == portName {
+			port = p.Port
+			break
+		}
+	}
+	return fmt.Sprintf("https://%v.%v.svc:%v", service, namespace, port), nil
+}
+
+// from describes the Service or Route a store URL was found from.
+func (d *discoverer) from(namespace, name string) string {
+	if d.Routes {
+		return fmt.Sprintf("Route %v/%v", namespace, name)
+	}
+	return fmt.Sprintf("Service %v/%v", namespace, name)
+}
+
+func ignoreMissing(err error, kind, namespace, name string) error {
+	if apierrors.IsNotFound(err) || notInstalled(err) {
+		log.V(2).Info("Discovery: not found", "kind", kind, "namespace", namespace, "name", name)
+		return nil
+	}
+	return fmt.Errorf("discover %v %v/%v: %w", kind, namespace, name, err)
+}
+
+// notInstalled returns true if the error means the resource kind is not known to the cluster.
+func notInstalled(err error) bool {
+	return meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) || apierrors.IsNotFound(err)
+}
+
+func resourceName(gvk schema.GroupVersionKind, o client.Object) string {
+	return fmt.Sprintf("%v %v/%v", gvk.Kind, o.GetNamespace(), o.GetName())
+}
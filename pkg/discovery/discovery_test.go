@@ -0,0 +1,206 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package discovery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newClient returns a fake client that knows the custom resource kinds in gvks.
+func newClient(t *testing.T, gvks []schema.GroupVersionKind, objects ...client.Object) client.Reader {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, routev1.AddToScheme(scheme))
+	for _, gvk := range gvks {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func resource(gvk schema.GroupVersionKind, namespace, name string, spec map[string]any) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	u.SetGroupVersionKind(gvk)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func service(namespace, name string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Spec: corev1.ServiceSpec{Ports: ports}}
+}
+
+func route(namespace, name, host string) *routev1.Route {
+	return &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       routev1.RouteSpec{Host: host, TLS: &routev1.TLSConfig{}},
+	}
+}
+
+var objects = []client.Object{
+	resource(LokiStack, "openshift-logging", "logging-loki", nil),
+	resource(LokiStack, "netobserv", "loki", nil),
+	resource(FlowCollector, "", "cluster", map[string]any{
+		"namespace": "netobserv",
+		"loki":      map[string]any{"lokiStack": map[string]any{"name": "loki"}},
+	}),
+	resource(TempoStack, "tracing", "platform", map[string]any{
+		"tenants": map[string]any{"authentication": []any{map[string]any{"tenantName": "dev"}, map[string]any{"tenantName": "prod"}}},
+	}),
+	service("openshift-monitoring", "thanos-querier", corev1.ServicePort{Name: "tenancy", Port: 9092}, corev1.ServicePort{Name: "web", Port: 9091}),
+	service("openshift-monitoring", "alertmanager-main", corev1.ServicePort{Name: "web", Port: 9094}),
+	service("openshift-logging", "logging-loki-gateway-http", corev1.ServicePort{Name: "gateway-http", Port: 8080}),
+	service("netobserv", "loki-gateway-http", corev1.ServicePort{Name: "gateway-http", Port: 8080}),
+	service("tracing", "tempo-platform-gateway", corev1.ServicePort{Name: "public", Port: 8080}),
+	route("openshift-monitoring", "thanos-querier", "thanos.example.com"),
+	route("openshift-monitoring", "alertmanager-main", "alerts.example.com"),
+	route("openshift-logging", "logging-loki", "logs.example.com"),
+	route("netobserv", "loki", "flows.example.com"),
+	route("tracing", "tempo-platform-gateway", "traces.example.com"),
+}
+
+func TestDiscover_services(t *testing.T) {
+	c := newClient(t, []schema.GroupVersionKind{LokiStack, TempoStack, FlowCollector}, objects...)
+	stores, err := Discover(context.Background(), c, Options{CertificateAuthority: "ca.crt"})
+	require.NoError(t, err)
+	assert.Equal(t, []config.Store{
+		{"domain": "metric", "metric": "https://thanos-querier.openshift-monitoring.svc:9091",
+			"discovered": "Service openshift-monitoring/thanos-querier", "certificateAuthority": "ca.crt"},
+		{"domain": "alert", "metrics": "https://thanos-querier.openshift-monitoring.svc:9091", "alertmanager": "https://alertmanager-main.openshift-monitoring.svc:9094",
+			"discovered": "Service openshift-monitoring/alertmanager-main", "certificateAuthority": "ca.crt"},
+		{"domain": "netflow", "lokiStack": "https://loki-gateway-http.netobserv.svc:8080",
+			"discovered": "LokiStack netobserv/loki", "certificateAuthority": "ca.crt"},
+		{"domain": "log", "lokiStack": "https://logging-loki-gateway-http.openshift-logging.svc:8080",
+			"discovered": "LokiStack openshift-logging/logging-loki", "certificateAuthority": "ca.crt"},
+		{"domain": "trace", "tempoStack": "https://tempo-platform-gateway.tracing.svc:8080/api/traces/v1/dev/tempo/api/search", "tenant": "dev",
+			"discovered": "TempoStack tracing/platform", "certificateAuthority": "ca.crt"},
+		{"domain": "trace", "tempoStack": "https://tempo-platform-gateway.tracing.svc:8080/api/traces/v1/prod/tempo/api/search", "tenant": "prod",
+			"discovered": "TempoStack tracing/platform", "certificateAuthority": "ca.crt"},
+	}, stores)
+}
+
+func TestDiscover_routes(t *testing.T) {
+	c := newClient(t, []schema.GroupVersionKind{LokiStack, TempoStack, FlowCollector}, objects...)
+	stores, err := Discover(context.Background(), c, Options{Routes: true})
+	require.NoError(t, err)
+	var urls []string
+	for _, s := range stores {
+		assert.NotContains(t, s, config.StoreKeyCA)
+		urls = append(urls, s["metric"]+s["alertmanager"]+s["lokiStack"]+s["tempoStack"])
+	}
+	assert.Equal(t, []string{
+		"https://thanos.example.com",
+		"https://alerts.example.com",
+		"https://flows.example.com",
+		"https://logs.example.com",
+		"https://traces.example.com/api/traces/v1/dev/tempo/api/search",
+		"https://traces.example.com/api/traces/v1/prod/tempo/api/search",
+	}, urls)
+}
+
+func TestDiscover_notInstalled(t *testing.T) {
+	// No custom resource kinds, only the cluster monitoring services.
+	c := newClient(t, nil, service("openshift-monitoring", "thanos-querier", corev1.ServicePort{Name: "web", Port: 9091}))
+	stores, err := Discover(context.Background(), c, Options{})
+	require.NoError(t, err)
+	require.Len(t, stores, 1)
+	assert.Equal(t, "metric", stores[0][config.StoreKeyDomain])
+	assert.Equal(t, ServiceCAFile, stores[0][config.StoreKeyCA])
+}
+
+func TestMerge(t *testing.T) {
+	stores := []config.Store{{"domain": "log", "lokiStack": "x"}, {"domain": "metric", "metric": "y"}}
+	configs := config.Configs{
+		{Source: "file", Stores: []config.Store{{"domain": "log"}}},
+		{Source: Source, Stores: []config.Store{{"domain": "trace"}}},
+	}
+	merged := Merge(configs, stores)
+	require.Len(t, merged, 2)
+	assert.Equal(t, config.Config{Source: Source, Stores: []config.Store{{"domain": "metric", "metric": "y"}}}, merged[1])
+	assert.Len(t, configs, 2, "original not modified")
+}
+
+func TestRefresher(t *testing.T) {
+	r := &Refresher{Client: newClient(t, nil, service("openshift-monitoring", "thanos-querier"))}
+	changed, err := r.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed, "no ports, no stores")
+	r.Client = newClient(t, nil, service("openshift-monitoring", "thanos-querier", corev1.ServicePort{Port: 9091}))
+	changed, err = r.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "https://thanos-querier.openshift-monitoring.svc:9091", r.Stores()[0]["metric"])
+	changed, err = r.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+// failReader fails to list the kind gvk.
+type failReader struct {
+	client.Reader
+	gvk schema.GroupVersionKind
+}
+
+func (r failReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if list.GetObjectKind().GroupVersionKind().Kind == r.gvk.Kind+"List" {
+		return errors.New("forbidden")
+	}
+	return r.Reader.List(ctx, list, opts...)
+}
+
+func domains(stores []config.Store) (domains []string) {
+	for _, s := range stores {
+		domains = append(domains, s[config.StoreKeyDomain])
+	}
+	return domains
+}
+
+func TestDiscover_partial(t *testing.T) {
+	c := failReader{Reader: newClient(t, []schema.GroupVersionKind{LokiStack, TempoStack, FlowCollector}, objects...), gvk: TempoStack}
+	stores, err := Discover(context.Background(), c, Options{})
+	assert.ErrorContains(t, err, "forbidden")
+	assert.Equal(t, []string{"metric", "alert", "netflow", "log"}, domains(stores))
+}
+
+func TestRefresher_partial(t *testing.T) {
+	gvks := []schema.GroupVersionKind{LokiStack, TempoStack, FlowCollector}
+	ctx := context.Background()
+
+	// No previous stores, use what was found.
+	r := &Refresher{Client: failReader{Reader: newClient(t, gvks, objects...), gvk: TempoStack}}
+	changed, err := r.Refresh(ctx)
+	assert.ErrorContains(t, err, "forbidden")
+	assert.True(t, changed)
+	assert.Equal(t, []string{"metric", "alert", "netflow", "log"}, domains(r.Stores()))
+
+	r.Client = newClient(t, gvks, objects...)
+	changed, err = r.Refresh(ctx)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"metric", "alert", "netflow", "log", "trace", "trace"}, domains(r.Stores()))
+
+	// Keep previous stores for the failed discoverer, update the others.
+	noAlerts := slices.DeleteFunc(slices.Clone(objects), func(o client.Object) bool { return o.GetName() == Alertmanager })
+	r.Client = failReader{Reader: newClient(t, gvks, noAlerts...), gvk: TempoStack}
+	changed, err = r.Refresh(ctx)
+	assert.ErrorContains(t, err, "forbidden")
+	assert.True(t, changed)
+	assert.Equal(t, []string{"metric", "netflow", "log", "trace", "trace"}, domains(r.Stores()))
+}
@@ -0,0 +1,102 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package discovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Merge returns configs with a configuration containing discovered stores, replacing any previous one.
+// Stores are only added for domains that have no stores in the other configurations,
+// explicitly configured stores take precedence.
+func Merge(configs config.Configs, stores []config.Store) config.Configs {
+	configs = slices.DeleteFunc(slices.Clone(configs), func(c config.Config) bool { return c.Source == Source })
+	configured := map[string]bool{}
+	for _, c := range configs {
+		for _, s := range c.Stores {
+			configured[s[config.StoreKeyDomain]] = true
+		}
+	}
+	discovered := config.Config{Source: Source}
+	for _, s := range stores {
+		if !configured[s[config.StoreKeyDomain]] {
+			discovered.Stores = append(discovered.Stores, s)
+		}
+	}
+	return append(configs, discovered)
+}
+
+// Refresher repeats discovery periodically, and calls Update when the discovered stores change.
+type Refresher struct {
+	Client   client.Reader
+	Options  Options
+	Interval time.Duration               // Time between refreshes, default 5m.
+	Update   func(stores []config.Store) // Called by Run with new stores when they change.
+
+	lock   sync.Mutex
+	found  [][]config.Store // Stores from each discoverer.
+	stores []config.Store
+}
+
+// Stores returns the stores found by the most recent refresh.
+func (r *Refresher) Stores() []config.Store {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stores
+}
+
+// Refresh discovers stores, returns true if they are different from the previous refresh.
+// Results are merged separately for each discoverer: if a discoverer fails, its stores from the
+// previous refresh are kept, so a resource that can't be reached does not remove stores.
+// If there are no previous stores, the stores it found before failing are used.
+// Stores from discoverers that succeed are updated, the returned error joins the errors from the others.
+func (r *Refresher) Refresh(ctx context.Context) (changed bool, err error) {
+	found, errs := discover(ctx, r.Client, r.Options)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, err := range errs {
+		if err != nil && i < len(r.found) && len(r.found[i]) > 0 {
+			found[i] = r.found[i]
+		}
+	}
+	r.found = found
+	stores := slices.Concat(found...)
+	if !reflect.DeepEqual(stores, r.stores) {
+		r.stores = stores
+		changed = true
+	}
+	return changed, errors.Join(errs...)
+}
+
+// Run refreshes every Interval until the context is cancelled.
+func (r *Refresher) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval == 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			changed, err := r.Refresh(ctx)
+			if err != nil {
+				log.Error(err, "Discovering stores")
+			}
+			if changed && r.Update != nil {
+				log.Info("Discovered stores changed", "stores", len(r.Stores()))
+				r.Update(r.Stores())
+			}
+		}
+	}
+}
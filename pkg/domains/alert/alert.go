@@ -52,11 +52,12 @@ import (
 )
 
 var (
-	_ korrel8r.Domain = Domain
-	_ korrel8r.Class  = Class{}
-	_ korrel8r.Query  = Query{}
-	_ korrel8r.Store  = &Store{}
-	_ korrel8r.Object = &Object{}
+	_ korrel8r.Domain       = Domain
+	_ korrel8r.QueryBuilder = Domain
+	_ korrel8r.Class        = Class{}
+	_ korrel8r.Query        = Query{}
+	_ korrel8r.Store        = &Store{}
+	_ korrel8r.Object       = &Object{}
 )
 
 var Domain = domain{}
@@ -73,11 +74,28 @@ func (d domain) Query(s string) (korrel8r.Query, error) {
 	return query, err
 }
 
+// BuildQuery builds a label matcher query for a mapping rule. Parameters are alert label names.
+func (d domain) BuildQuery(_ korrel8r.Class, params map[string]string) (korrel8r.Query, error) {
+	q := Query{}
+	for k, v := range params {
+		if !model.LabelName(k).IsValid() {
+			return nil, fmt.Errorf("invalid label name for %v query: %q", d, k)
+		}
+		q[k] = v
+	}
+	return q, nil
+}
+
 const (
 	StoreKeyMetrics      = "metrics"
 	StoreKeyAlertmanager = "alertmanager"
 )
 
+// StoreKeys returns the store configuration keys for this domain.
+func (domain) StoreKeys() []string {
+	return append([]string{StoreKeyMetrics, StoreKeyAlertmanager}, k8s.HTTPStoreKeys...)
+}
+
 func (domain) Store(s any) (korrel8r.Store, error) {
 	cs, err := impl.TypeAssert[config.Store](s)
 	if err != nil {
@@ -7,6 +7,8 @@ import (
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test/domain"
 	"github.com/korrel8r/korrel8r/pkg/domains/alert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TODO https://github.com/korrel8r/korrel8r/issues/148  store does not respect limits. Remove SkipCluster when fixed.
@@ -14,3 +16,11 @@ var fixture = domain.Fixture{Query: alert.Query{}, SkipCluster: true}
 
 func TestAlertDomain(t *testing.T)      { fixture.Test(t) }
 func BenchmarkAlertDomain(b *testing.B) { fixture.Benchmark(b) }
+
+func TestDomain_BuildQuery(t *testing.T) {
+	got, err := alert.Domain.BuildQuery(nil, map[string]string{"namespace": "foo", "pod": "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, alert.Query{"namespace": "foo", "pod": "bar"}, got)
+	_, err = alert.Domain.BuildQuery(nil, map[string]string{"a-b": "x"})
+	assert.EqualError(t, err, `invalid label name for alert query: "a-b"`)
+}
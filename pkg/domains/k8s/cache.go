@@ -0,0 +1,105 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StoreKeyCacheNamespaces is a comma-separated list of namespaces to cache, required with [config.StoreKeyCache].
+// Caching all namespaces of a large cluster could use unbounded memory, so it is not allowed.
+const StoreKeyCacheNamespaces = "cacheNamespaces"
+
+// storeCache is an informer cache for a set of classes, used by a cluster [Store].
+//
+// The cache is started by the first query for a cached class,
+// and an informer for each class is started by the first query for that class.
+// Queries for other classes, other namespaces or with field selectors read from the API server.
+//
+// Cached objects do not include managed fields.
+// Informers use the store credentials, so a query for a REST request with a user identity only uses the cache
+// if the user is allowed to read the class in the namespace, see [Store.reader].
+type storeCache struct {
+	gvks       map[schema.GroupVersionKind]bool
+	namespaces map[string]bool
+	start      func(context.Context) (client.Reader, error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	reader client.Reader
+	err    error
+}
+
+// newStoreCache returns a cache for the [config.StoreKeyCache] classes of a store, or nil if there are none.
+func newStoreCache(cfg *rest.Config, sc config.Store) (*storeCache, error) {
+	names := splitList(sc[config.StoreKeyCache])
+	if len(names) == 0 {
+		return nil, nil
+	}
+	c := &storeCache{gvks: map[schema.GroupVersionKind]bool{}, namespaces: map[string]bool{}}
+	for _, name := range names {
+		class, _ := Domain.Class(name).(Class)
+		if class == (Class{}) {
+			return nil, fmt.Errorf("%v: unknown class: %v", config.StoreKeyCache, name)
+		}
+		c.gvks[class.GVK()] = true
+	}
+	namespaces := splitList(sc[StoreKeyCacheNamespaces])
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("%v: %v is required, caching all namespaces is not allowed", config.StoreKeyCache, StoreKeyCacheNamespaces)
+	}
+	opts := cache.Options{Scheme: Scheme, DefaultTransform: cache.TransformStripManagedFields(), DefaultNamespaces: map[string]cache.Config{}}
+	for _, ns := range namespaces {
+		c.namespaces[ns] = true
+		opts.DefaultNamespaces[ns] = cache.Config{}
+	}
+	c.start = func(ctx context.Context) (client.Reader, error) {
+		ca, err := cache.New(cfg, opts)
+		if err != nil {
+			return nil, err
+		}
+		go func() { _ = ca.Start(ctx) }()
+		return ca, nil
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c, nil
+}
+
+// Reader returns the cache if it can serve the query, nil otherwise.
+// Safe to call with c == nil.
+func (c *storeCache) Reader(q *Query) (client.Reader, error) {
+	if c == nil || !c.gvks[q.GVK()] || len(q.Fields) > 0 || !c.namespaces[q.Namespace] {
+		return nil, nil
+	}
+	c.once.Do(func() { c.reader, c.err = c.start(c.ctx) })
+	return c.reader, c.err
+}
+
+// Close stops the cache. Safe to call with c == nil.
+func (c *storeCache) Close() error {
+	if c != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring spaces and empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
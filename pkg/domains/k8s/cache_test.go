@@ -0,0 +1,73 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStoreCache(t *testing.T) {
+	newClient := func(names ...string) client.Client {
+		b := fake.NewClientBuilder().WithScheme(Scheme).WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(Scheme)).
+			WithIndex(&corev1.Pod{}, "metadata.name", func(o client.Object) []string { return []string{o.GetName()} })
+		for _, name := range names {
+			b = b.WithObjects(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}})
+		}
+		return b.Build()
+	}
+	sc, err := newStoreCache(&rest.Config{}, config.Store{config.StoreKeyCache: "Pod, ConfigMap", StoreKeyCacheNamespaces: "ns"})
+	require.NoError(t, err)
+	started := 0
+	sc.start = func(context.Context) (client.Reader, error) { started++; return newClient("cached"), nil }
+	s, err := NewStore(newClient("live"), &rest.Config{})
+	require.NoError(t, err)
+	s.(*Store).cache = sc
+	defer s.(*Store).Close()
+
+	pod := ClassOf(&corev1.Pod{})
+	for _, x := range []struct {
+		name string
+		q    *Query
+		want string
+	}{
+		{"uncached class", NewQuery(ClassOf(&corev1.Service{}), "ns", "", nil, nil), ""},
+		{"uncached namespace", NewQuery(pod, "other", "", nil, nil), ""},
+		{"all namespaces", NewQuery(pod, "", "", nil, nil), "live"},
+		{"fields", NewQuery(pod, "ns", "", nil, client.MatchingFields{"metadata.name": "live"}), "live"},
+		{"list", NewQuery(pod, "ns", "", nil, nil), "cached"},
+		{"get", NewQuery(pod, "ns", "cached", nil, nil), "cached"},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			var result korrel8r.ListResult
+			require.NoError(t, s.Get(context.Background(), x.q, nil, &result))
+			var got string
+			for _, o := range result {
+				got += o.(Object).GetName()
+			}
+			assert.Equal(t, x.want, got)
+		})
+	}
+	assert.Equal(t, 1, started, "cache started once")
+}
+
+func TestNewStoreCache(t *testing.T) {
+	sc, err := newStoreCache(&rest.Config{}, config.Store{})
+	assert.NoError(t, err)
+	assert.Nil(t, sc)
+	_, err = newStoreCache(&rest.Config{}, config.Store{config.StoreKeyCache: "Pod,Nonesuch", StoreKeyCacheNamespaces: "ns"})
+	assert.EqualError(t, err, "cache: unknown class: Nonesuch")
+	_, err = newStoreCache(&rest.Config{}, config.Store{config.StoreKeyCache: "Pod"})
+	assert.EqualError(t, err, "cache: cacheNamespaces is required, caching all namespaces is not allowed")
+}
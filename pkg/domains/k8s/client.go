@@ -3,21 +3,27 @@
 package k8s
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	kconfig "github.com/korrel8r/korrel8r/pkg/config"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"github.com/korrel8r/korrel8r/pkg/rest/auth"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // SetLogger sets the logger for controller-runtime.
 func SetLogger(l logr.Logger) {
-	log.SetLogger(l)
+	ctrllog.SetLogger(l)
 }
 
 // NewClient provides a general-purpose k8s client.
@@ -32,28 +38,201 @@ func NewClient(cfg *rest.Config) (c client.Client, err error) {
 	return client.New(cfg, client.Options{Scheme: Scheme})
 }
 
-// NewHTTPClient returns a new client with TLS settingsn from Store config.
+// HTTPStoreKeys are the store keys understood by [NewHTTPClient],
+// for domains with stores that connect over HTTP.
+var HTTPStoreKeys = []string{
+	kconfig.StoreKeyToken,
+	kconfig.StoreKeyTokenFile,
+	kconfig.StoreKeyUsername,
+	kconfig.StoreKeyPassword,
+	kconfig.StoreKeyClientCert,
+	kconfig.StoreKeyClientKey,
+	kconfig.StoreKeyProxy,
+	kconfig.StoreKeyInsecureSkipVerify,
+	kconfig.StoreKeyHeaderPrefix + "*",
+}
+
+// NewHTTPClient returns a new client with TLS and authentication settings from Store config, see [HTTPStoreKeys].
+//
+// Settings that are not in the store config are taken from the default configuration, see [GetConfig].
+// Authorization forwarded from an incoming REST request takes precedence over store credentials.
 func NewHTTPClient(s kconfig.Store) (*http.Client, error) {
 	cfg, err := GetConfig()
 	if err != nil {
 		return nil, err
 	}
-	ca := s[kconfig.StoreKeyCA]
-	if ca != "" {
-		cfg.TLSClientConfig.CAFile = ca
+	if err := applyStoreConfig(cfg, s); err != nil {
+		return nil, err
 	}
 	return rest.HTTPClientFor(cfg)
 }
 
+// applyStoreConfig applies HTTP store keys to a rest.Config.
+func applyStoreConfig(cfg *rest.Config, s kconfig.Store) error {
+	if ca := s[kconfig.StoreKeyCA]; ca != "" {
+		cfg.TLSClientConfig.CAFile = ca
+	}
+	if v := s[kconfig.StoreKeyInsecureSkipVerify]; v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%v: %w", kconfig.StoreKeyInsecureSkipVerify, err)
+		}
+		if insecure {
+			cfg.TLSClientConfig.Insecure = true
+			cfg.TLSClientConfig.CAFile, cfg.TLSClientConfig.CAData = "", nil // Not allowed with Insecure.
+		}
+	}
+	cert, key := s[kconfig.StoreKeyClientCert], s[kconfig.StoreKeyClientKey]
+	if (cert == "") != (key == "") {
+		return fmt.Errorf("%v and %v must be set together", kconfig.StoreKeyClientCert, kconfig.StoreKeyClientKey)
+	}
+	if cert != "" {
+		cfg.TLSClientConfig.CertFile, cfg.TLSClientConfig.KeyFile = cert, key
+		cfg.TLSClientConfig.CertData, cfg.TLSClientConfig.KeyData = nil, nil
+	}
+	token, tokenFile, user, password := s[kconfig.StoreKeyToken], s[kconfig.StoreKeyTokenFile], s[kconfig.StoreKeyUsername], s[kconfig.StoreKeyPassword]
+	switch {
+	case token != "" && tokenFile != "":
+		return fmt.Errorf("can't set both %v and %v", kconfig.StoreKeyToken, kconfig.StoreKeyTokenFile)
+	case (token != "" || tokenFile != "") && (user != "" || password != ""):
+		return fmt.Errorf("can't set both %v and %v", kconfig.StoreKeyTokenFile, kconfig.StoreKeyUsername)
+	case token != "":
+		cfg.BearerToken, cfg.BearerTokenFile = token, ""
+		cfg.Username, cfg.Password = "", ""
+	case tokenFile != "":
+		// The token file is re-read periodically, so rotated tokens are picked up.
+		cfg.BearerToken, cfg.BearerTokenFile = "", tokenFile
+		cfg.Username, cfg.Password = "", ""
+	case user != "" || password != "":
+		cfg.Username, cfg.Password = user, password
+		cfg.BearerToken, cfg.BearerTokenFile = "", ""
+	}
+	if p := s[kconfig.StoreKeyProxy]; p != "" {
+		u, err := url.Parse(p)
+		if err != nil {
+			return fmt.Errorf("%v: %w", kconfig.StoreKeyProxy, err)
+		}
+		cfg.Proxy = http.ProxyURL(u)
+	}
+	if v := s[kconfig.StoreKeyMaxResponseSize]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%v: %w", kconfig.StoreKeyMaxResponseSize, err)
+		}
+		if n > 0 {
+			cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper { return &limitRoundTripper{limit: n, next: rt} })
+		}
+	}
+	header := http.Header{}
+	for k, v := range s {
+		if name, ok := strings.CutPrefix(k, kconfig.StoreKeyHeaderPrefix); ok && name != "" {
+			header.Set(name, v)
+		}
+	}
+	if len(header) > 0 {
+		cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper { return &headerRoundTripper{header: header, next: rt} })
+	}
+	return nil
+}
+
+// limitRoundTripper returns an error reading a response body that is larger than limit.
+type limitRoundTripper struct {
+	limit int64
+	next  http.RoundTripper
+}
+
+func (rt *limitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err == nil && resp.Body != nil {
+		if resp.ContentLength > rt.limit {
+			resp.Body.Close()
+			return nil, fmt.Errorf("response too large: %v bytes, limit is %v", resp.ContentLength, rt.limit)
+		}
+		resp.Body = &limitBody{ReadCloser: resp.Body, limit: rt.limit}
+	}
+	return resp, err
+}
+
+// limitBody returns an error if more than limit bytes are read.
+type limitBody struct {
+	io.ReadCloser
+	limit, read int64
+}
+
+func (b *limitBody) Read(p []byte) (int, error) {
+	if max := b.limit - b.read + 1; int64(len(p)) > max {
+		p = p[:max] // Read one byte past the limit to detect overflow.
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), fmt.Errorf("response too large, limit is %v bytes", b.limit)
+	}
+	return n, err
+}
+
+// headerRoundTripper adds static headers to requests.
+type headerRoundTripper struct {
+	header http.Header
+	next   http.RoundTripper
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range rt.header {
+		req.Header[k] = v
+	}
+	return rt.next.RoundTrip(req)
+}
+
 // GetConfig returns a rest.Config with settings for use by korrel8r.
 func GetConfig() (*rest.Config, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, err
 	}
+	setLimits(cfg)
+	cfg.Wrap(auth.Wrap)
+	return cfg, nil
+}
+
+// Namespace returns the namespace of the current kubeconfig context,
+// or the namespace of the korrel8r pod when running in a cluster.
+func Namespace() (string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	ns, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).Namespace()
+	return ns, err
+}
+
+func setLimits(cfg *rest.Config) {
 	// TODO make these configurable.
 	cfg.QPS = float32(korrel8r.DefaultLimit)
 	cfg.Burst = korrel8r.DefaultLimit
-	cfg.Wrap(auth.Wrap)
+}
+
+// storeRESTConfig returns the configuration to connect to the cluster for a k8s store, with the store credentials.
+//
+// A store with a server or kubeconfig context connects to another cluster.
+// Authorization from an incoming REST request is not included, see [StoreKeyAuth].
+func storeRESTConfig(s kconfig.Store) (cfg *rest.Config, err error) {
+	server, kubeContext := s[StoreKeyServer], s[StoreKeyContext]
+	switch {
+	case server != "" && kubeContext != "":
+		return nil, fmt.Errorf("can't set both %v and %v", StoreKeyServer, StoreKeyContext)
+	case server != "":
+		cfg = &rest.Config{Host: server}
+	case kubeContext != "":
+		if cfg, err = config.GetConfigWithContext(kubeContext); err != nil {
+			return nil, err
+		}
+	default:
+		if cfg, err = config.GetConfig(); err != nil {
+			return nil, err
+		}
+	}
+	setLimits(cfg)
+	if err := applyStoreConfig(cfg, s); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
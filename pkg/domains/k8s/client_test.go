@@ -0,0 +1,147 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestApplyStoreConfig(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Header }))
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("my-token\n"), 0600))
+
+	for _, x := range []struct {
+		name  string
+		store config.Store
+		want  http.Header
+	}{
+		{
+			name:  "tokenFile",
+			store: config.Store{config.StoreKeyTokenFile: tokenFile},
+			want:  http.Header{"Authorization": {"Bearer my-token"}},
+		},
+		{
+			name:  "token",
+			store: config.Store{config.StoreKeyToken: "my-token"},
+			want:  http.Header{"Authorization": {"Bearer my-token"}},
+		},
+		{
+			name:  "basic",
+			store: config.Store{config.StoreKeyUsername: "me", config.StoreKeyPassword: "pw"},
+			want:  http.Header{"Authorization": {"Basic bWU6cHc="}},
+		},
+		{
+			name:  "headers",
+			store: config.Store{config.StoreKeyHeaderPrefix + "X-Scope-OrgID": "tenant", config.StoreKeyHeaderPrefix + "x-other": "foo"},
+			want:  http.Header{"X-Scope-Orgid": {"tenant"}, "X-Other": {"foo"}},
+		},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			cfg := &rest.Config{BearerToken: "default-token"}
+			require.NoError(t, applyStoreConfig(cfg, x.store))
+			hc, err := rest.HTTPClientFor(cfg)
+			require.NoError(t, err)
+			resp, err := hc.Get(server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			for k := range x.want {
+				assert.Equal(t, x.want.Values(k), got.Values(k), k)
+			}
+			if x.want.Get("Authorization") == "" {
+				assert.Equal(t, "Bearer default-token", got.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestApplyStoreConfig_error(t *testing.T) {
+	for _, x := range []struct {
+		store config.Store
+		err   string
+	}{
+		{config.Store{config.StoreKeyClientCert: "cert"}, "clientCertificate and clientKey must be set together"},
+		{config.Store{config.StoreKeyTokenFile: "t", config.StoreKeyUsername: "u"}, "can't set both tokenFile and username"},
+		{config.Store{config.StoreKeyToken: "t", config.StoreKeyTokenFile: "f"}, "can't set both token and tokenFile"},
+		{config.Store{config.StoreKeyInsecureSkipVerify: "maybe"}, `insecureSkipVerify: strconv.ParseBool: parsing "maybe": invalid syntax`},
+	} {
+		t.Run(x.err, func(t *testing.T) {
+			assert.EqualError(t, applyStoreConfig(&rest.Config{}, x.store), x.err)
+		})
+	}
+}
+
+func TestApplyStoreConfig_tls(t *testing.T) {
+	cfg := &rest.Config{TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca"), CertData: []byte("cert"), KeyData: []byte("key")}}
+	require.NoError(t, applyStoreConfig(cfg, config.Store{
+		config.StoreKeyInsecureSkipVerify: "true",
+		config.StoreKeyClientCert:         "cert.pem",
+		config.StoreKeyClientKey:          "key.pem",
+		config.StoreKeyProxy:              "http://proxy:3128",
+	}))
+	assert.Equal(t, rest.TLSClientConfig{Insecure: true, CertFile: "cert.pem", KeyFile: "key.pem"}, cfg.TLSClientConfig)
+	u, err := cfg.Proxy(&http.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy:3128", u.String())
+}
+
+func TestApplyStoreConfig_maxResponseSize(t *testing.T) {
+	body := "0123456789"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("chunked") {
+			w.(http.Flusher).Flush() // Unknown content length.
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	for _, x := range []struct {
+		limit, query, err string
+	}{
+		{limit: "10", query: ""},
+		{limit: "10", query: "?chunked"},
+		{limit: "9", query: "", err: "response too large: 10 bytes, limit is 9"},
+		{limit: "9", query: "?chunked", err: "response too large, limit is 9 bytes"},
+	} {
+		t.Run(x.limit+x.query, func(t *testing.T) {
+			cfg := &rest.Config{}
+			require.NoError(t, applyStoreConfig(cfg, config.Store{config.StoreKeyMaxResponseSize: x.limit}))
+			hc, err := rest.HTTPClientFor(cfg)
+			require.NoError(t, err)
+			resp, err := hc.Get(server.URL + x.query)
+			if err == nil {
+				defer resp.Body.Close()
+				var b []byte
+				b, err = io.ReadAll(resp.Body)
+				if err == nil {
+					assert.Equal(t, body, string(b))
+				}
+			}
+			if x.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, x.err)
+			}
+		})
+	}
+}
+
+func TestStoreRESTConfig(t *testing.T) {
+	cfg, err := storeRESTConfig(config.Store{StoreKeyServer: "https://api.example.com:6443", config.StoreKeyToken: "my-token"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com:6443", cfg.Host)
+	assert.Equal(t, "my-token", cfg.BearerToken)
+	_, err = storeRESTConfig(config.Store{StoreKeyServer: "https://x", StoreKeyContext: "y"})
+	assert.EqualError(t, err, "can't set both server and context")
+}
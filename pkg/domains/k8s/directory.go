@@ -0,0 +1,193 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r/impl"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StoreKeyDirectory is the store key for a directory of resource files, see [DirectoryStore].
+const StoreKeyDirectory = "directory"
+
+// DirectoryStore serves queries from resource files in a directory,
+// for example a must-gather or `oc adm inspect` archive. No cluster connection is needed.
+//
+//	stores:
+//	  - domain: k8s
+//	    directory: ./must-gather.local.1234
+//
+// All YAML and JSON files under the directory are loaded.
+// A file may contain multiple documents, and documents may be lists of resources.
+// Resources with kinds that are not in [Scheme] are ignored.
+// Files that can't be parsed as resources are skipped.
+// If the same resource appears in more than one file, the first one loaded is used.
+//
+// Queries match namespace, name, labels and label selectors as for a cluster.
+// Field selectors are compared with the string value of the field path in the resource, for example `status.phase`.
+type DirectoryStore struct {
+	objects map[schema.GroupVersionKind][]Object
+	cluster string
+}
+
+var _ korrel8r.Store = &DirectoryStore{}
+
+// NewDirectoryStore loads all resource files under dir.
+func NewDirectoryStore(dir string) (*DirectoryStore, error) {
+	s := &DirectoryStore{objects: map[schema.GroupVersionKind][]Object{}}
+	seen := map[schema.GroupVersionKind]map[types.NamespacedName]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		objects, err := loadFile(path)
+		if err != nil {
+			return nil // Archives contain other YAML files, skip them.
+		}
+		for _, o := range objects {
+			gvk := o.GetObjectKind().GroupVersionKind()
+			key := client.ObjectKeyFromObject(o)
+			if seen[gvk] == nil {
+				seen[gvk] = map[types.NamespacedName]bool{}
+			}
+			if !seen[gvk][key] {
+				seen[gvk][key] = true
+				s.objects[gvk] = append(s.objects[gvk], o)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadFile loads all resources from a YAML or JSON file, expanding lists.
+func loadFile(path string) ([]Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var objects []Object
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		if err := decoder.Decode(&u.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if u.Object == nil {
+			continue // Empty document.
+		}
+		if !u.IsList() {
+			if o := toObject(u); o != nil {
+				objects = append(objects, o)
+			}
+			continue
+		}
+		// Items in a typed list like PodList may not have their own kind.
+		itemGVK := u.GroupVersionKind()
+		itemGVK.Kind = strings.TrimSuffix(itemGVK.Kind, "List")
+		_ = u.EachListItem(func(item runtime.Object) error {
+			iu := item.(*unstructured.Unstructured)
+			if iu.GetKind() == "" {
+				iu.SetGroupVersionKind(itemGVK)
+			}
+			if o := toObject(iu); o != nil {
+				objects = append(objects, o)
+			}
+			return nil
+		})
+	}
+}
+
+// toObject converts to a typed object, returns nil if the kind is not in Scheme.
+func toObject(u *unstructured.Unstructured) Object {
+	gvk := u.GroupVersionKind()
+	ro, err := Scheme.New(gvk)
+	if err != nil {
+		return nil
+	}
+	o, _ := ro.(Object)
+	if o == nil || runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, o) != nil {
+		return nil
+	}
+	o.GetObjectKind().SetGroupVersionKind(gvk)
+	return o
+}
+
+func (s *DirectoryStore) Domain() korrel8r.Domain { return Domain }
+
+func (s *DirectoryStore) Get(ctx context.Context, query korrel8r.Query, c *korrel8r.Constraint, result korrel8r.Appender) error {
+	q, err := impl.TypeAssert[*Query](query)
+	if err != nil {
+		return err
+	}
+	if q.Cluster != "" && q.Cluster != s.cluster {
+		return nil
+	}
+	selector, err := q.labelSelector()
+	if err != nil {
+		return err
+	}
+	limit := c.GetLimit()
+	count := 0
+	for _, o := range s.objects[q.class.GVK()] {
+		if limit > 0 && count >= limit {
+			break
+		}
+		if (q.Namespace != "" && o.GetNamespace() != q.Namespace) ||
+			(q.Name != "" && o.GetName() != q.Name) ||
+			!selector.Matches(labels.Set(o.GetLabels())) ||
+			!matchFields(o, q.Fields) ||
+			c.CompareTime(o.GetCreationTimestamp().Time) > 0 {
+			continue
+		}
+		result.Append(setCluster(o.DeepCopyObject().(Object), s.cluster))
+		count++
+	}
+	return nil
+}
+
+// matchFields returns true if the string value of each field path equals the expected value.
+func matchFields(o Object, fields client.MatchingFields) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+	if err != nil {
+		return false
+	}
+	for path, want := range fields {
+		v, ok, _ := unstructured.NestedFieldNoCopy(u, strings.Split(path, ".")...)
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDirectoryStore_Get(t *testing.T) {
+	s, err := Domain.Store(config.Store{StoreKeyDirectory: "testdata/must-gather"})
+	require.NoError(t, err)
+	end := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	pod, node := ClassOf(&corev1.Pod{}), ClassOf(&corev1.Node{})
+	for _, x := range []struct {
+		name       string
+		q          korrel8r.Query
+		constraint *korrel8r.Constraint
+		want       []string
+	}{
+		{"name", NewQuery(pod, "ns1", "p1", nil, nil), nil, []string{"ns1/p1"}},
+		{"namespace", NewQuery(pod, "ns1", "", nil, nil), nil, []string{"ns1/p1", "ns1/p2"}},
+		{"labels", NewQuery(pod, "", "", client.MatchingLabels{"app": "b"}, nil), nil, []string{"ns1/p2"}},
+		{"selector", &Query{Selector: "app notin (a)", class: pod}, nil, []string{"ns1/p2"}},
+		{"fields", NewQuery(pod, "", "", nil, client.MatchingFields{"status.phase": "Running", "spec.nodeName": "node1"}), nil, []string{"ns1/p1"}},
+		{"constraint", NewQuery(pod, "ns1", "", nil, nil), &korrel8r.Constraint{End: &end}, []string{"ns1/p1"}},
+		{"limit", NewQuery(pod, "ns1", "", nil, nil), &korrel8r.Constraint{Limit: ptr.To(1)}, []string{"ns1/p1"}},
+		{"cluster-scoped", NewQuery(node, "", "node1", nil, nil), nil, []string{"/node1"}},
+		{"multi-document", NewQuery(ClassOf(&appsv1.Deployment{}), "ns1", "", nil, nil), nil, []string{"ns1/d1"}},
+		{"none", NewQuery(ClassOf(&corev1.Service{}), "", "", nil, nil), nil, nil},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			var result korrel8r.ListResult
+			require.NoError(t, s.Get(context.Background(), x.q, x.constraint, &result))
+			var got []string
+			for _, o := range result {
+				got = append(got, client.ObjectKeyFromObject(o.(Object)).String())
+			}
+			assert.Equal(t, x.want, got)
+		})
+	}
+}
+
+func TestDirectoryStore_duplicate(t *testing.T) {
+	s, err := NewDirectoryStore("testdata/must-gather")
+	require.NoError(t, err)
+	var result korrel8r.ListResult
+	require.NoError(t, s.Get(context.Background(), NewQuery(ClassOf(&corev1.Pod{}), "ns1", "p1", nil, nil), nil, &result))
+	require.Len(t, result, 1)
+	// Files are loaded in lexical order, core/pods.yaml before pods/p1/p1.yaml.
+	assert.Equal(t, "a", result[0].(*corev1.Pod).Labels["app"])
+}
+
+func TestDirectoryStore_error(t *testing.T) {
+	_, err := NewDirectoryStore("testdata/nonesuch")
+	assert.Error(t, err)
+}
+
+func TestDirectoryStore_cluster(t *testing.T) {
+	s, err := Domain.Store(config.Store{StoreKeyDirectory: "testdata/must-gather", config.StoreKeyCluster: "east"})
+	require.NoError(t, err)
+	q := NewQuery(ClassOf(&corev1.Pod{}), "ns1", "p1", nil, nil)
+	var result korrel8r.ListResult
+	require.NoError(t, s.Get(context.Background(), q, nil, &result))
+	require.Len(t, result, 1)
+	assert.Equal(t, "east", Domain.ObjectCluster(result[0]))
+
+	result = nil
+	require.NoError(t, s.Get(context.Background(), q.WithCluster("east"), nil, &result))
+	assert.Len(t, result, 1)
+	result = nil
+	require.NoError(t, s.Get(context.Background(), q.WithCluster("west"), nil, &result))
+	assert.Empty(t, result)
+}
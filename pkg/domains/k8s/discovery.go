@@ -0,0 +1,125 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// discovered holds classes found by API discovery that are not in [Scheme], for each open cluster [Store].
+// Classes of a store are removed when it is closed, so they do not outlive the engine that created the store.
+// Objects of discovered classes are [unstructured.Unstructured].
+var discovered = &discoveredClasses{stores: map[*Store][]schema.GroupVersionKind{}}
+
+// discoveredClasses is a concurrent-safe set of GVKs for each store.
+type discoveredClasses struct {
+	lock   sync.RWMutex
+	stores map[*Store][]schema.GroupVersionKind
+}
+
+func (d *discoveredClasses) add(s *Store, gvks []schema.GroupVersionKind) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.stores[s] = gvks
+}
+
+func (d *discoveredClasses) remove(s *Store) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.stores, s)
+}
+
+func (d *discoveredClasses) has(gvk schema.GroupVersionKind) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, gvks := range d.stores {
+		if slices.Contains(gvks, gvk) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the GVKs of all stores sorted by group, kind and version.
+func (d *discoveredClasses) list() []schema.GroupVersionKind {
+	d.lock.RLock()
+	var gvks []schema.GroupVersionKind
+	for _, more := range d.stores {
+		gvks = append(gvks, more...)
+	}
+	d.lock.RUnlock()
+	slices.SortFunc(gvks, func(a, b schema.GroupVersionKind) int {
+		return strings.Compare(a.Group+"/"+a.Kind+"/"+a.Version, b.Group+"/"+b.Kind+"/"+b.Version)
+	})
+	return slices.Compact(gvks)
+}
+
+// find returns the first GVK that matches, in [discoveredClasses.list] order.
+func (d *discoveredClasses) find(match func(schema.GroupVersionKind) bool) (schema.GroupVersionKind, bool) {
+	for _, gvk := range d.list() {
+		if match(gvk) {
+			return gvk, true
+		}
+	}
+	return schema.GroupVersionKind{}, false
+}
+
+// discoverClasses returns the preferred version of each listable resource that is not in [Scheme].
+// Errors for API groups that are unavailable are ignored, resources from other groups are still returned.
+func discoverClasses(dc discovery.DiscoveryInterface) ([]schema.GroupVersionKind, error) {
+	lists, err := dc.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	var gvks []schema.GroupVersionKind
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			gvk := gv.WithKind(r.Kind)
+			if strings.Contains(r.Name, "/") || !slices.Contains(r.Verbs, "list") || Scheme.Recognizes(gvk) {
+				continue // Sub-resource, not listable, or already known.
+			}
+			gvks = append(gvks, gvk)
+		}
+	}
+	return gvks, nil
+}
+
+// versionPattern matches Kubernetes API versions like v1, v1beta2, v2alpha1.
+var versionPattern = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// customClass returns a class for a fully-qualified custom resource name KIND.VERSION.GROUP.
+// Custom resource groups always contain a '.', this distinguishes them from built-in types.
+func customClass(gvk schema.GroupVersionKind) (Class, bool) {
+	if gvk.Kind != "" && versionPattern.MatchString(gvk.Version) && strings.Contains(gvk.Group, ".") {
+		return Class(gvk), true
+	}
+	return Class{}, false
+}
+
+// newObject returns a typed object if gvk is in [Scheme], otherwise an unstructured object.
+// List kinds return an [unstructured.UnstructuredList].
+func newObject(gvk schema.GroupVersionKind) runtime.Object {
+	if o, err := Scheme.New(gvk); err == nil {
+		return o
+	}
+	if strings.HasSuffix(gvk.Kind, "List") {
+		l := &unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(gvk)
+		return l
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	return u
+}
@@ -0,0 +1,124 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeDiscovery struct {
+	discovery.DiscoveryInterface
+	lists []*metav1.APIResourceList
+	err   error
+}
+
+func (f fakeDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return f.lists, f.err
+}
+
+func TestDiscoverClasses(t *testing.T) {
+	list := []string{"get", "list", "watch"}
+	dc := fakeDiscovery{
+		lists: []*metav1.APIResourceList{
+			{GroupVersion: "gadgets.example.com/v1beta1", APIResources: []metav1.APIResource{
+				{Name: "gadgets", Kind: "Gadget", Namespaced: true, Verbs: list},
+				{Name: "gadgets/status", Kind: "Gadget", Namespaced: true, Verbs: []string{"get"}},
+				{Name: "gadgetreviews", Kind: "GadgetReview", Verbs: []string{"create"}},
+			}},
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: list}}},
+		},
+		err: &discovery.ErrGroupDiscoveryFailed{Groups: map[schema.GroupVersion]error{{Group: "metrics.k8s.io", Version: "v1"}: errors.New("unavailable")}},
+	}
+	gvks, err := discoverClasses(dc)
+	require.NoError(t, err)
+	assert.NotContains(t, gvks, schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+	s := &Store{}
+	discovered.add(s, gvks)
+	gadget := Class{Group: "gadgets.example.com", Version: "v1beta1", Kind: "Gadget"}
+	for _, name := range []string{"Gadget", "Gadget.gadgets.example.com", "Gadget.v1beta1.gadgets.example.com"} {
+		assert.Equal(t, gadget, Domain.Class(name), name)
+	}
+	assert.Nil(t, Domain.Class("GadgetReview"))
+	assert.Contains(t, Domain.Classes(), korrel8r.Class(gadget))
+
+	// Classes are forgotten when the store that discovered them is closed.
+	require.NoError(t, s.Close())
+	assert.Nil(t, Domain.Class("Gadget"))
+	assert.NotContains(t, Domain.Classes(), korrel8r.Class(gadget))
+
+	_, err = discoverClasses(fakeDiscovery{err: errors.New("connection refused")})
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestDomain_Store_discoveryForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+	s, err := Domain.Store(config.Store{StoreKeyServer: server.URL})
+	require.NoError(t, err, "store is usable without discovered classes")
+	defer s.(*Store).Close()
+	assert.Empty(t, discovered.list())
+}
+
+func TestDomain_Class_custom(t *testing.T) {
+	// Fully-qualified custom resource names are classes before discovery.
+	assert.Equal(t, Class{Group: "widgets.example.com", Version: "v1alpha1", Kind: "Widget"}, Domain.Class("Widget.v1alpha1.widgets.example.com"))
+	assert.Nil(t, Domain.Class("Widget.widgets.example.com"))
+	assert.Nil(t, Domain.Class("Widget"))
+	assert.Nil(t, Domain.Class("Widget.v1.core"))
+}
+
+func TestStore_Get_unstructured(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "widgets.example.com", Version: "v1", Kind: "Widget"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind("WidgetList"), &unstructured.UnstructuredList{})
+	widget := &unstructured.Unstructured{}
+	widget.SetGroupVersionKind(gvk)
+	widget.SetNamespace("ns")
+	widget.SetName("w")
+	require.NoError(t, unstructured.SetNestedField(widget.Object, "blue", "spec", "color"))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme)).WithObjects(widget).Build()
+	s, err := NewStore(c, &rest.Config{})
+	require.NoError(t, err)
+
+	for _, q := range []*Query{NewQuery(Class(gvk), "ns", "", nil, nil), NewQuery(Class(gvk), "ns", "w", nil, nil)} {
+		var result korrel8r.ListResult
+		require.NoError(t, s.Get(context.Background(), q, nil, &result))
+		require.Len(t, result, 1, q.String())
+		u := result[0].(*unstructured.Unstructured)
+		assert.Equal(t, gvk, u.GroupVersionKind())
+		color, _, _ := unstructured.NestedString(u.Object, "spec", "color")
+		assert.Equal(t, "blue", color)
+	}
+
+	o, err := Class(gvk).Unmarshal([]byte(`{"metadata":{"name":"x"},"spec":{"color":"red"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, gvk, o.(*unstructured.Unstructured).GroupVersionKind())
+	assert.Equal(t, "x", o.(Object).GetName())
+
+	// Rule templates use methods for metadata and the Object map for other fields.
+	var b strings.Builder
+	require.NoError(t, template.Must(template.New("").Parse(`{{.GetName}}:{{.Object.spec.color}}`)).Execute(&b, o))
+	assert.Equal(t, "x:red", b.String())
+}
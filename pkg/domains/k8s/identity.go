@@ -0,0 +1,217 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/rest/auth"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StoreKeyAuth selects the identity used for API requests made for an incoming REST request.
+//
+//   - `forward`: use the bearer token from the REST request. Default for the current cluster.
+//   - `impersonate`: use the store credentials, impersonating the user that owns the REST request token.
+//     The store credentials must be allowed to create TokenReviews and impersonate users and groups.
+//   - `none`: use the store credentials. Default for stores with a `server` or `context`.
+//
+// Requests that do not come from the REST API, for example from the command line, always use the store credentials.
+// A REST request with no bearer token is anonymous for `forward` and `impersonate`.
+const StoreKeyAuth = "auth"
+
+// Values for [StoreKeyAuth].
+const (
+	AuthForward     = "forward"
+	AuthImpersonate = "impersonate"
+	AuthNone        = "none"
+)
+
+// Limits for cached identity clients.
+const (
+	identityTTL   = 5 * time.Minute // Tokens are reviewed again after this time.
+	maxIdentities = 100
+)
+
+// identityClients creates and caches a client for each user identity of incoming REST requests.
+type identityClients struct {
+	mode      string
+	config    *rest.Config  // Configuration with store credentials.
+	client    client.Client // Client with store credentials, for token reviews.
+	newClient func(*rest.Config) (client.Client, error)
+
+	lock    sync.Mutex
+	clients map[string]*identityClient // Key is a hash of the token.
+}
+
+type identityClient struct {
+	client  client.Client
+	expires time.Time
+
+	lock    sync.Mutex
+	reviews map[string]bool // Access review results, see [identityClient.can].
+}
+
+// newIdentityClients returns nil if the [StoreKeyAuth] mode is `none`.
+func newIdentityClients(cfg *rest.Config, c client.Client, sc config.Store) (*identityClients, error) {
+	mode := sc[StoreKeyAuth]
+	if mode == "" {
+		mode = AuthForward
+		if sc[StoreKeyServer] != "" || sc[StoreKeyContext] != "" {
+			mode = AuthNone
+		}
+	}
+	switch mode {
+	case AuthNone:
+		return nil, nil
+	case AuthForward, AuthImpersonate:
+		return &identityClients{mode: mode, config: cfg, client: c, newClient: NewClient, clients: map[string]*identityClient{}}, nil
+	default:
+		return nil, fmt.Errorf("invalid value for %v: %q", StoreKeyAuth, mode)
+	}
+}
+
+// Client returns a client for the identity of the REST request in ctx.
+// Returns nil if ctx is not from a REST request. Safe to call with ic == nil.
+func (ic *identityClients) Client(ctx context.Context) (client.Client, error) {
+	id, err := ic.identity(ctx)
+	if id == nil || err != nil {
+		return nil, err
+	}
+	return id.client, nil
+}
+
+// identity returns the identity client of the REST request in ctx, or nil if ctx is not from a REST request.
+// Safe to call with ic == nil.
+func (ic *identityClients) identity(ctx context.Context) (*identityClient, error) {
+	authorization, ok := auth.FromContext(ctx)
+	if ic == nil || !ok {
+		return nil, nil
+	}
+	token, _ := strings.CutPrefix(authorization, "Bearer ")
+	if token == authorization {
+		token = "" // Not a bearer token.
+	}
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+	ic.lock.Lock()
+	e, ok := ic.clients[key]
+	ic.lock.Unlock()
+	if ok && now.Before(e.expires) {
+		return e, nil
+	}
+	cfg, err := ic.userConfig(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ic.newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e = &identityClient{client: c, expires: now.Add(identityTTL), reviews: map[string]bool{}}
+	ic.add(key, e, now)
+	return e, nil
+}
+
+// can returns true if the user is allowed to use verb on resources of class gvk in namespace.
+// It creates a SelfSubjectAccessReview with the user client, results are kept as long as the client.
+// Returns false if the review fails, the user's own request will report the problem.
+//
+// Objects read with the store credentials, for example from a cache, can be returned to users that are allowed to read them.
+func (c *identityClient) can(ctx context.Context, verb string, gvk schema.GroupVersionKind, namespace string) bool {
+	m, err := c.client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	key := strings.Join([]string{verb, m.Resource.Group, m.Resource.Resource, namespace}, "/")
+	c.lock.Lock()
+	allowed, ok := c.reviews[key]
+	c.lock.Unlock()
+	if ok {
+		return allowed
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: namespace, Verb: verb, Group: m.Resource.Group, Version: m.Resource.Version, Resource: m.Resource.Resource,
+		}}}
+	if err := c.client.Create(ctx, review); err != nil {
+		return false // Don't keep the result, the error may be temporary.
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reviews[key] = review.Status.Allowed
+	return review.Status.Allowed
+}
+
+// add a client, evicting expired clients, or the oldest client, if the cache is full.
+func (ic *identityClients) add(key string, e *identityClient, now time.Time) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	if len(ic.clients) >= maxIdentities {
+		oldest := ""
+		for k, v := range ic.clients {
+			if now.After(v.expires) {
+				delete(ic.clients, k)
+			} else if oldest == "" || v.expires.Before(ic.clients[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(ic.clients) >= maxIdentities {
+			delete(ic.clients, oldest)
+		}
+	}
+	ic.clients[key] = e
+}
+
+// userConfig returns the configuration for a user token. An empty token is anonymous.
+func (ic *identityClients) userConfig(ctx context.Context, token string) (*rest.Config, error) {
+	if token == "" {
+		return withoutCredentials(ic.config), nil
+	}
+	if ic.mode == AuthForward {
+		cfg := withoutCredentials(ic.config)
+		cfg.BearerToken = token
+		return cfg, nil
+	}
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := ic.client.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return withoutCredentials(ic.config), nil
+	}
+	user := review.Status.User
+	cfg := rest.CopyConfig(ic.config)
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: user.Username, UID: user.UID, Groups: user.Groups}
+	if len(user.Extra) > 0 {
+		cfg.Impersonate.Extra = map[string][]string{}
+		for k, v := range user.Extra {
+			cfg.Impersonate.Extra[k] = v
+		}
+	}
+	return cfg, nil
+}
+
+// withoutCredentials returns a copy of cfg with no client credentials.
+// Other settings, including TLS server verification and transport wrappers, are kept.
+func withoutCredentials(cfg *rest.Config) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+	cfg.BearerToken, cfg.BearerTokenFile = "", ""
+	cfg.Username, cfg.Password = "", ""
+	cfg.Impersonate = rest.ImpersonationConfig{}
+	cfg.AuthProvider, cfg.ExecProvider = nil, nil
+	cfg.CertFile, cfg.KeyFile, cfg.CertData, cfg.KeyData = "", "", nil, nil
+	return cfg
+}
@@ -0,0 +1,161 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/rest/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestNewIdentityClients(t *testing.T) {
+	for _, x := range []struct {
+		store config.Store
+		mode  string
+	}{
+		{config.Store{}, AuthForward},
+		{config.Store{StoreKeyServer: "https://x"}, ""},
+		{config.Store{StoreKeyContext: "x", StoreKeyAuth: AuthForward}, AuthForward},
+		{config.Store{StoreKeyAuth: AuthImpersonate}, AuthImpersonate},
+		{config.Store{StoreKeyAuth: AuthNone}, ""},
+	} {
+		ic, err := newIdentityClients(&rest.Config{}, nil, x.store)
+		require.NoError(t, err)
+		if x.mode == "" {
+			assert.Nil(t, ic, "%v", x.store)
+		} else if assert.NotNil(t, ic, "%v", x.store) {
+			assert.Equal(t, x.mode, ic.mode)
+		}
+	}
+	_, err := newIdentityClients(&rest.Config{}, nil, config.Store{StoreKeyAuth: "maybe"})
+	assert.EqualError(t, err, `invalid value for auth: "maybe"`)
+}
+
+func TestIdentityClients_Client(t *testing.T) {
+	storeConfig := &rest.Config{Host: "https://api", BearerToken: "store-token", TLSClientConfig: rest.TLSClientConfig{CAFile: "ca.crt"}}
+	reviewer := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+			r := o.(*authenticationv1.TokenReview)
+			if r.Spec.Token == "user-token" {
+				r.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{
+					Username: "alice", UID: "1", Groups: []string{"dev"}, Extra: map[string]authenticationv1.ExtraValue{"k": {"v"}}}}
+			}
+			return nil
+		}}).Build()
+	requestContext := func(authorization string) context.Context {
+		return auth.Context(&http.Request{Header: http.Header{"Authorization": {authorization}}})
+	}
+
+	for _, mode := range []string{AuthForward, AuthImpersonate} {
+		t.Run(mode, func(t *testing.T) {
+			ic, err := newIdentityClients(storeConfig, reviewer, config.Store{StoreKeyAuth: mode})
+			require.NoError(t, err)
+			var configs []*rest.Config
+			ic.newClient = func(cfg *rest.Config) (client.Client, error) {
+				configs = append(configs, cfg)
+				return fake.NewClientBuilder().Build(), nil
+			}
+
+			c, err := ic.Client(context.Background())
+			require.NoError(t, err)
+			assert.Nil(t, c, "not a REST request")
+
+			c1, err := ic.Client(requestContext("Bearer user-token"))
+			require.NoError(t, err)
+			c2, err := ic.Client(requestContext("Bearer user-token"))
+			require.NoError(t, err)
+			assert.Same(t, c1, c2, "cached per identity")
+			require.Len(t, configs, 1)
+			assert.Equal(t, "ca.crt", configs[0].CAFile)
+			if mode == AuthForward {
+				assert.Equal(t, "user-token", configs[0].BearerToken)
+				assert.Empty(t, configs[0].Impersonate)
+			} else {
+				assert.Equal(t, "store-token", configs[0].BearerToken)
+				assert.Equal(t, rest.ImpersonationConfig{UserName: "alice", UID: "1", Groups: []string{"dev"}, Extra: map[string][]string{"k": {"v"}}}, configs[0].Impersonate)
+			}
+
+			for _, authorization := range []string{"", "Basic eDp5"} {
+				_, err = ic.Client(requestContext(authorization))
+				require.NoError(t, err)
+				cfg := configs[len(configs)-1]
+				assert.Empty(t, cfg.BearerToken, "anonymous")
+				assert.Empty(t, cfg.Impersonate, "anonymous")
+			}
+		})
+	}
+}
+
+func TestIdentityClients_Client_unauthenticated(t *testing.T) {
+	reviewer := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(context.Context, client.WithWatch, client.Object, ...client.CreateOption) error { return nil },
+	}).Build()
+	ic, err := newIdentityClients(&rest.Config{BearerToken: "store-token"}, reviewer, config.Store{StoreKeyAuth: AuthImpersonate})
+	require.NoError(t, err)
+	var got *rest.Config
+	ic.newClient = func(cfg *rest.Config) (client.Client, error) { got = cfg; return nil, nil }
+	_, err = ic.Client(auth.Context(&http.Request{Header: http.Header{"Authorization": {"Bearer bad-token"}}}))
+	require.NoError(t, err)
+	assert.Empty(t, got.BearerToken)
+	assert.Empty(t, got.Impersonate)
+}
+
+func TestStore_Get_identity(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+	newClient := func(name string) client.Client {
+		return fake.NewClientBuilder().WithScheme(Scheme).WithRESTMapper(mapper).WithObjects(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}}).Build()
+	}
+	s, err := NewStore(newClient("store"), &rest.Config{})
+	require.NoError(t, err)
+	ic, err := newIdentityClients(&rest.Config{}, nil, config.Store{})
+	require.NoError(t, err)
+	reviews := 0
+	ic.newClient = func(cfg *rest.Config) (client.Client, error) {
+		return interceptor.NewClient(newClient("user").(client.WithWatch), interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+				r := o.(*authorizationv1.SelfSubjectAccessReview)
+				reviews++
+				a := r.Spec.ResourceAttributes
+				r.Status.Allowed = cfg.BearerToken == "admin-token" && a.Verb == "list" && a.Resource == "pods" && a.Namespace == "ns"
+				return nil
+			}}), nil
+	}
+	sc, err := newStoreCache(&rest.Config{}, config.Store{config.StoreKeyCache: "Pod", StoreKeyCacheNamespaces: "ns"})
+	require.NoError(t, err)
+	sc.start = func(context.Context) (client.Reader, error) { return newClient("cached"), nil }
+	s.(*Store).identities, s.(*Store).cache = ic, sc
+	defer s.(*Store).Close()
+
+	q := NewQuery(ClassOf(&corev1.Pod{}), "ns", "", nil, nil)
+	for _, x := range []struct {
+		ctx  context.Context
+		want string
+	}{
+		{context.Background(), "cached"},
+		{auth.Context(&http.Request{Header: http.Header{"Authorization": {"Bearer user-token"}}}), "user"},
+		{auth.Context(&http.Request{Header: http.Header{"Authorization": {"Bearer admin-token"}}}), "cached"}, // Allowed to list.
+		{auth.Context(&http.Request{Header: http.Header{"Authorization": {"Bearer admin-token"}}}), "cached"},
+	} {
+		var result korrel8r.ListResult
+		require.NoError(t, s.Get(x.ctx, q, nil, &result))
+		require.Len(t, result, 1)
+		assert.Equal(t, x.want, result[0].(Object).GetName())
+	}
+	assert.Equal(t, 2, reviews, "one review per identity")
+}
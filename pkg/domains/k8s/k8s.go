@@ -10,6 +10,54 @@
 //	 stores:
 //		  domain: k8s
 //
+// A store with a `directory` key reads resources from files instead of a cluster, see [DirectoryStore].
+//
+// There can be multiple stores for different clusters, each with a `cluster` name and one of:
+//   - `context`: a kubeconfig context.
+//   - `server`: an API server URL, with `token` or `tokenFile` and other HTTP connection keys.
+//
+// Objects from a named cluster have a [ClusterAnnotation], queries with a `cluster` field only use stores for that cluster.
+// Rules propagate the cluster: queries generated from an object of a named cluster only use stores for the same cluster.
+//
+//	stores:
+//	  - domain: k8s
+//	    cluster: hub
+//	  - domain: k8s
+//	    cluster: east
+//	    server: https://api.east.example.com:6443
+//	    tokenFile: /etc/korrel8r/east/token
+//
+// A cluster store can cache resources in memory with informers, for faster queries and less load on the API server.
+// The `cache` key is a comma-separated list of classes or aliases to cache,
+// `cacheNamespaces` is a required comma-separated list of namespaces to cache, to bound memory use.
+// Queries for other classes or namespaces, and queries with field selectors, read from the API server.
+// Queries for a REST request with a user identity are served from the cache if the user is allowed to read them,
+// checked with a SelfSubjectAccessReview, see [StoreKeyAuth].
+//
+//	stores:
+//	  - domain: k8s
+//	    cache: workloads,Event
+//	    cacheNamespaces: app1,app2
+//
+// A cluster store makes requests for a REST API user with the user's token, or by impersonating the user.
+// The `auth` key selects `forward`, `impersonate` or `none`, see [StoreKeyAuth].
+//
+//	stores:
+//	  - domain: k8s
+//	    auth: impersonate
+//
+// # Custom Resources
+//
+// Cluster stores discover API resources from the API server when they are created,
+// discovered classes are known until the store is closed.
+// Resources that are not built-in types, for example operator custom resources, become classes with
+// [unstructured.Unstructured] objects. A fully-qualified name `KIND.VERSION.GROUP` can be used for a
+// custom resource before it is discovered, for example in rules.
+//
+// Rule templates for custom resources use methods for metadata, and the Object map for other fields:
+//
+//	k8s:Pod:{"namespace": "{{.GetNamespace}}", "selector": "app={{.Object.spec.appName}}"}
+//
 // [Kubernetes]: https://kubernetes.io/docs/concepts/overview/
 package k8s
 
@@ -21,13 +69,18 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/korrel8r/korrel8r/internal/pkg/logging"
 	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/pkg/config"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r/impl"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
@@ -57,14 +110,20 @@ type Object client.Object
 // Example:
 //
 //	k8s:Pod.v1.:{"namespace":"openshift-cluster-version","name":"cluster-version-operator-8d86bcb65-btlgn"}
+//	k8s:Pod.v1.:{"namespace":"openshift-cluster-version","selector":"k8s-app=cluster-version-operator,tier notin (canary)"}
 type Query struct {
 	// Namespace restricts the search to a namespace.
 	Namespace string `json:"namespace,omitempty"`
 	Name      string `json:"name,omitempty"`
 	// Labels restricts the search to objects with matching label values (optional)
 	Labels client.MatchingLabels `json:"labels,omitempty"`
+	// Selector restricts the search to objects matching a label selector string,
+	// for example `app in (a,b),!canary`. Combined with Labels if both are set. (optional)
+	Selector string `json:"selector,omitempty"`
 	// Fields restricts the search to objects with matching field values (optional)
 	Fields client.MatchingFields `json:"fields,omitempty"`
+	// Cluster restricts the search to stores for the named cluster (optional)
+	Cluster string `json:"cluster,omitempty"`
 
 	class Class // class is the underlying k8s.Class object. Implied by query name prefix.
 }
@@ -77,16 +136,22 @@ type Query struct {
 //	 stores:
 //		  domain: k8s
 type Store struct {
-	c    client.Client
-	base *url.URL
+	c          client.Client
+	base       *url.URL
+	cluster    string
+	cache      *storeCache
+	identities *identityClients
 }
 
 // Validate interfaces
+var log = logging.Log()
+
 var (
-	_ korrel8r.Domain = Domain
-	_ korrel8r.Class  = Class{}
-	_ korrel8r.Object = Object(nil)
-	_ korrel8r.Query  = &Query{}
+	_ korrel8r.Domain       = Domain
+	_ korrel8r.QueryBuilder = Domain
+	_ korrel8r.Class        = Class{}
+	_ korrel8r.Object       = Object(nil)
+	_ korrel8r.Query        = &Query{}
 )
 
 // domain implementation
@@ -95,16 +160,83 @@ type domain struct{}
 func (d domain) Name() string        { return "k8s" }
 func (d domain) String() string      { return d.Name() }
 func (d domain) Description() string { return "Resource objects in a Kubernetes API server" }
-func (d domain) Store(_ any) (s korrel8r.Store, err error) {
-	cfg, err := GetConfig()
+
+// Store keys for the k8s domain.
+const (
+	StoreKeyContext = "context" // Kubeconfig context for the cluster.
+	StoreKeyServer  = "server"  // API server URL for the cluster, use with token or tokenFile.
+)
+
+// ClusterAnnotation is set on objects from a store with a [config.StoreKeyCluster] name.
+const ClusterAnnotation = "korrel8r.openshift.io/cluster"
+
+// StoreKeys returns the store configuration keys for this domain.
+func (d domain) StoreKeys() []string {
+	return append([]string{StoreKeyDirectory, StoreKeyContext, StoreKeyServer, config.StoreKeyCache, StoreKeyCacheNamespaces, StoreKeyAuth}, HTTPStoreKeys...)
+}
+
+// Redactions returns the built-in redactions for this domain.
+// Secret data is removed, including the copy in the last-applied-configuration annotation.
+func (d domain) Redactions() []config.Redaction {
+	return []config.Redaction{{
+		Domain:  d.Name(),
+		Classes: []string{"Secret"},
+		Remove:  []string{"data", "stringData", `metadata.annotations["kubectl.kubernetes.io/last-applied-configuration"]`},
+	}}
+}
+
+func (d domain) Store(cfg any) (korrel8r.Store, error) {
+	sc, _ := cfg.(config.Store)
+	if sc[StoreKeyDirectory] != "" {
+		s, err := NewDirectoryStore(sc[StoreKeyDirectory])
+		if err != nil {
+			return nil, err
+		}
+		s.cluster = sc[config.StoreKeyCluster]
+		return s, nil
+	}
+	restConfig, err := storeRESTConfig(sc)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	classes, err := discoverClasses(dc)
+	if err != nil {
+		// The store can still query known classes, for example if listing API resources is forbidden.
+		log.Error(err, "Cannot discover custom resource classes", "host", restConfig.Host)
+	}
+	c, err := NewClient(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := newStoreCache(restConfig, sc)
 	if err != nil {
 		return nil, err
 	}
-	c, err := NewClient(cfg)
+	identities, err := newIdentityClients(restConfig, c, sc)
 	if err != nil {
 		return nil, err
 	}
-	return NewStore(c, cfg)
+	s, err := NewStore(c, restConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.(*Store).cluster = sc[config.StoreKeyCluster]
+	s.(*Store).cache = cache
+	s.(*Store).identities = identities
+	discovered.add(s.(*Store), classes)
+	return s, nil
+}
+
+// ObjectCluster returns the cluster name from the [ClusterAnnotation] of an object, or "".
+func (d domain) ObjectCluster(o korrel8r.Object) string {
+	if o, _ := o.(Object); o != nil {
+		return o.GetAnnotations()[ClusterAnnotation]
+	}
+	return ""
 }
 
 func (d domain) Class(name string) korrel8r.Class {
@@ -114,20 +246,33 @@ func (d domain) Class(name string) korrel8r.Class {
 	if gvk.Kind, s, ok = strings.Cut(name, "."); !ok { // Just Kind
 		return classForKind(gvk.Kind)
 	}
-	if gvk.Version, gvk.Group = s, ""; Scheme.Recognizes(gvk) { // Kind.Version
+	if gvk.Version, gvk.Group = s, ""; recognizes(gvk) { // Kind.Version
 		return Class(gvk)
 	}
-	if gvk.Version, gvk.Group, ok = strings.Cut(s, "."); ok && Scheme.Recognizes(gvk) { // s == Kind.Version.Group
-		return Class(gvk)
+	if gvk.Version, gvk.Group, ok = strings.Cut(s, "."); ok { // s == Kind.Version.Group
+		if recognizes(gvk) {
+			return Class(gvk)
+		}
+		if c, ok := customClass(gvk); ok {
+			return c
+		}
 	}
 	gvk.Version, gvk.Group = "", s // s == Kind.Group
 	return classForGK(gvk.GroupKind())
 }
 
+// recognizes returns true if gvk is in [Scheme] or was discovered.
+func recognizes(gvk schema.GroupVersionKind) bool {
+	return Scheme.Recognizes(gvk) || discovered.has(gvk)
+}
+
 func classForGK(gk schema.GroupKind) korrel8r.Class {
 	if versions := Scheme.VersionsForGroupKind(gk); len(versions) > 0 {
 		return Class(gk.WithVersion(versions[0].Version))
 	}
+	if gvk, ok := discovered.find(func(gvk schema.GroupVersionKind) bool { return gvk.GroupKind() == gk }); ok {
+		return Class(gvk)
+	}
 	return nil
 }
 
@@ -138,6 +283,9 @@ func classForKind(kind string) korrel8r.Class {
 			return Class(gvk)
 		}
 	}
+	if gvk, ok := discovered.find(func(gvk schema.GroupVersionKind) bool { return gvk.Kind == kind }); ok {
+		return Class(gvk)
+	}
 	return nil
 }
 
@@ -145,6 +293,9 @@ func (d domain) Classes() (classes []korrel8r.Class) {
 	for gvk := range Scheme.AllKnownTypes() {
 		classes = append(classes, Class(gvk))
 	}
+	for _, gvk := range discovered.list() {
+		classes = append(classes, Class(gvk))
+	}
 	return classes
 }
 
@@ -154,9 +305,49 @@ func (d domain) Query(s string) (korrel8r.Query, error) {
 		return nil, err
 	}
 	query.class = class.(Class)
+	if _, err := query.labelSelector(); err != nil {
+		return nil, err
+	}
 	return &query, nil
 }
 
+// BuildQuery builds a query for a mapping rule. Parameters are:
+//
+//	namespace, name: namespace and name of the resource.
+//	labels.KEY: match label KEY.
+//	fields.KEY: match field KEY.
+//	selector: label selector string.
+func (d domain) BuildQuery(c korrel8r.Class, params map[string]string) (korrel8r.Query, error) {
+	class, err := impl.TypeAssert[Class](c)
+	if err != nil {
+		return nil, err
+	}
+	q := NewQuery(class, "", "", nil, nil)
+	for k, v := range params {
+		switch {
+		case k == "namespace":
+			q.Namespace = v
+		case k == "name":
+			q.Name = v
+		case strings.HasPrefix(k, "labels."):
+			if q.Labels == nil {
+				q.Labels = client.MatchingLabels{}
+			}
+			q.Labels[strings.TrimPrefix(k, "labels.")] = v
+		case strings.HasPrefix(k, "fields."):
+			if q.Fields == nil {
+				q.Fields = client.MatchingFields{}
+			}
+			q.Fields[strings.TrimPrefix(k, "fields.")] = v
+		case k == "selector":
+			q.Selector = v
+		default:
+			return nil, fmt.Errorf("invalid parameter for %v query: %v", d, k)
+		}
+	}
+	return q, nil
+}
+
 // ClassOf returns the Class of o, which must be a pointer to a typed API resource struct.
 func ClassOf(o client.Object) Class { return Class(GroupVersionKind(o)) }
 
@@ -187,11 +378,16 @@ func (c Class) Preview(o korrel8r.Object) string {
 
 func (c Class) Domain() korrel8r.Domain { return Domain }
 func (c Class) Unmarshal(b []byte) (korrel8r.Object, error) {
-	if o, err := Scheme.New(schema.GroupVersionKind(c)); err == nil {
-		err := json.Unmarshal(b, &o)
-		return o, err
+	o := newObject(c.GVK())
+	if u, ok := o.(*unstructured.Unstructured); ok {
+		if err := json.Unmarshal(b, &u.Object); err != nil {
+			return nil, err
+		}
+		u.SetGroupVersionKind(c.GVK()) // Data may not have a kind.
+		return u, nil
 	}
-	return nil, fmt.Errorf("unknown k8s type: %v", c)
+	err := json.Unmarshal(b, o)
+	return o, err
 }
 func (c Class) Name() string   { return fmt.Sprintf("%v.%v.%v", c.Kind, c.Version, c.Group) }
 func (c Class) String() string { return impl.ClassString(c) }
@@ -225,6 +421,23 @@ func (q Query) Data() string                 { b, _ := json.Marshal(q); return s
 func (q Query) String() string               { return impl.QueryString(q) }
 func (q Query) GVK() schema.GroupVersionKind { return q.class.GVK() }
 
+// labelSelector returns a selector combining Selector and Labels.
+func (q Query) labelSelector() (labels.Selector, error) {
+	selector, err := labels.Parse(q.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	requirements, _ := labels.SelectorFromSet(labels.Set(q.Labels)).Requirements()
+	return selector.Add(requirements...), nil
+}
+
+// WithCluster returns a copy of the query restricted to a cluster.
+func (q *Query) WithCluster(cluster string) korrel8r.Query {
+	q2 := *q
+	q2.Cluster = cluster
+	return &q2
+}
+
 // NewStore creates a new k8s store.
 func NewStore(c client.Client, cfg *rest.Config) (korrel8r.Store, error) {
 	host := cfg.Host
@@ -232,12 +445,52 @@ func NewStore(c client.Client, cfg *rest.Config) (korrel8r.Store, error) {
 		host = "localhost"
 	}
 	base, _, err := rest.DefaultServerURL(host, cfg.APIPath, schema.GroupVersion{}, true)
-	return &Store{c: c, base: base}, err
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{c: c, base: base}
+	topology.addStore(s)
+	return s, nil
 }
 
 func (s Store) Domain() korrel8r.Domain { return Domain }
 func (s Store) Client() client.Client   { return s.c }
 
+// Close stops the store cache, if there is one, and forgets classes discovered by the store.
+func (s *Store) Close() error {
+	topology.removeStore(s)
+	discovered.remove(s)
+	return s.cache.Close()
+}
+
+// reader returns a reader for q: the cache if it can serve q and the user of a REST request is allowed to read it,
+// otherwise the client for the user identity of a REST request, or the store client.
+func (s *Store) reader(ctx context.Context, q *Query) (client.Reader, error) {
+	user, err := s.identities.identity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.cache.Reader(q)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && (user == nil || user.can(ctx, queryVerb(q), q.GVK(), q.Namespace)) {
+		return r, nil
+	}
+	if user != nil {
+		return user.client, nil
+	}
+	return s.c, nil
+}
+
+// queryVerb is the API verb used to evaluate q.
+func queryVerb(q *Query) string {
+	if q.Name != "" {
+		return "get"
+	}
+	return "list"
+}
+
 func (s *Store) Get(ctx context.Context, query korrel8r.Query, c *korrel8r.Constraint, result korrel8r.Appender) (err error) {
 	defer func() {
 		if errors.IsNotFound(err) {
@@ -249,10 +502,13 @@ func (s *Store) Get(ctx context.Context, query korrel8r.Query, c *korrel8r.Const
 	if err != nil {
 		return err
 	}
+	if q.Cluster != "" && q.Cluster != s.cluster {
+		return nil
+	}
 	appender := korrel8r.FuncAppender(func(o korrel8r.Object) {
 		// Include only objects created before or during the constraint interval.
 		if c.CompareTime(o.(Object).GetCreationTimestamp().Time) <= 0 {
-			result.Append(o)
+			result.Append(setCluster(o.(Object), s.cluster))
 		}
 	})
 	if q.Name != "" { // Request for single object.
@@ -262,6 +518,19 @@ func (s *Store) Get(ctx context.Context, query korrel8r.Query, c *korrel8r.Const
 	}
 }
 
+// setCluster sets the [ClusterAnnotation] if cluster is not empty.
+func setCluster(o Object, cluster string) Object {
+	if cluster != "" {
+		a := o.GetAnnotations()
+		if a == nil {
+			a = map[string]string{}
+		}
+		a[ClusterAnnotation] = cluster
+		o.SetAnnotations(a)
+	}
+	return o
+}
+
 func setMeta(o Object) Object {
 	gvk := must.Must1(apiutil.GVKForObject(o, Scheme))
 	o.GetObjectKind().SetGroupVersionKind(gvk)
@@ -269,15 +538,16 @@ func setMeta(o Object) Object {
 }
 
 func (s *Store) getObject(ctx context.Context, q *Query, result korrel8r.Appender) error {
-	o, err := Scheme.New(q.class.GVK())
-	if err != nil {
-		return err
-	}
+	o := newObject(q.class.GVK())
 	co, _ := o.(client.Object)
 	if co == nil {
 		return fmt.Errorf("invalid client.Object: %T", o)
 	}
-	err = s.c.Get(ctx, NamespacedName(q.Namespace, q.Name), co)
+	r, err := s.reader(ctx, q)
+	if err != nil {
+		return err
+	}
+	err = r.Get(ctx, NamespacedName(q.Namespace, q.Name), co)
 	if err != nil {
 		return err
 	}
@@ -288,10 +558,7 @@ func (s *Store) getObject(ctx context.Context, q *Query, result korrel8r.Appende
 func (s *Store) getList(ctx context.Context, q *Query, result korrel8r.Appender, c *korrel8r.Constraint) error {
 	gvk := q.class.GVK()
 	gvk.Kind = gvk.Kind + "List"
-	o, err := Scheme.New(gvk)
-	if err != nil {
-		return err
-	}
+	o := newObject(gvk)
 	list, _ := o.(client.ObjectList)
 	if list == nil {
 		return fmt.Errorf("invalid list object %T", o)
@@ -300,7 +567,13 @@ func (s *Store) getList(ctx context.Context, q *Query, result korrel8r.Appender,
 	if q.Namespace != "" {
 		opts = append(opts, client.InNamespace(q.Namespace))
 	}
-	if len(q.Labels) > 0 {
+	if q.Selector != "" {
+		selector, err := q.labelSelector()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	} else if len(q.Labels) > 0 {
 		opts = append(opts, q.Labels)
 	}
 	if len(q.Fields) > 0 {
@@ -309,7 +582,11 @@ func (s *Store) getList(ctx context.Context, q *Query, result korrel8r.Appender,
 	if limit := c.GetLimit(); limit > 0 {
 		opts = append(opts, client.Limit(int64(limit)))
 	}
-	if err := s.c.List(ctx, list, opts...); err != nil {
+	r, err := s.reader(ctx, q)
+	if err != nil {
+		return err
+	}
+	if err := r.List(ctx, list, opts...); err != nil {
 		return err
 	}
 	defer func() { // Handle reflect panics.
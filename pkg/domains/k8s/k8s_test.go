@@ -65,6 +65,8 @@ func TestDomain_Query(t *testing.T) {
 		{`k8s:Pod:{namespace: foo, name: bar}`, NewQuery(ClassOf(&corev1.Pod{}), "foo", "bar", nil, nil)},
 		{`k8s:Pod:{namespace: foo, name: bar, labels: { a: b }, fields: { c: d }}`,
 			NewQuery(ClassOf(&corev1.Pod{}), "foo", "bar", map[string]string{"a": "b"}, map[string]string{"c": "d"})},
+		{`k8s:Pod:{namespace: foo, selector: "a in (b,c),!d"}`,
+			&Query{Namespace: "foo", Selector: "a in (b,c),!d", class: ClassOf(&corev1.Pod{})}},
 	} {
 		t.Run(x.s, func(t *testing.T) {
 			got, err := Domain.Query(x.s)
@@ -83,6 +85,7 @@ func TestDomain_Query_error(t *testing.T) {
 	}{
 		// Detect common error: yaml map with missing space interpreted as key containing '"'
 		{`k8s:Namespace:{name:"foo"}`, "unknown field"},
+		{`k8s:Pod:{selector: "a in b"}`, "invalid selector"},
 	} {
 		t.Run(x.s, func(t *testing.T) {
 			_, err := Domain.Query(x.s)
@@ -92,6 +95,15 @@ func TestDomain_Query_error(t *testing.T) {
 
 }
 
+func TestDomain_BuildQuery(t *testing.T) {
+	c := ClassOf(&corev1.Pod{})
+	got, err := Domain.BuildQuery(c, map[string]string{"namespace": "foo", "name": "bar", "labels.a": "b", "fields.c": "d"})
+	require.NoError(t, err)
+	assert.Equal(t, NewQuery(c, "foo", "bar", map[string]string{"a": "b"}, map[string]string{"c": "d"}), got)
+	_, err = Domain.BuildQuery(c, map[string]string{"nonesuch": "x"})
+	assert.EqualError(t, err, "invalid parameter for k8s query: nonesuch")
+}
+
 func TestStore_Get(t *testing.T) {
 	c := fake.NewClientBuilder().
 		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)).
@@ -121,6 +133,9 @@ func TestStore_Get(t *testing.T) {
 		{NewQuery(Class(podGVK), "x", "fred", nil, nil), []types.NamespacedName{fred}},
 		{NewQuery(Class(podGVK), "x", "", nil, nil), []types.NamespacedName{fred, barney}},
 		{NewQuery(Class(podGVK), "", "", client.MatchingLabels{"app": "foo"}, nil), []types.NamespacedName{fred, wilma}},
+		{&Query{Selector: "app in (foo,bad)", class: Class(podGVK)}, []types.NamespacedName{fred, barney, wilma}},
+		{&Query{Selector: "app notin (foo)", class: Class(podGVK)}, []types.NamespacedName{barney}},
+		{&Query{Namespace: "x", Selector: "app", Labels: client.MatchingLabels{"app": "foo"}, class: Class(podGVK)}, []types.NamespacedName{fred}},
 	} {
 		t.Run(fmt.Sprintf("%#v", x.q), func(t *testing.T) {
 			var result korrel8r.ListResult
@@ -18,7 +18,7 @@ import (
 
 var Scheme = apiruntime.NewScheme()
 
-// TODO extend this set, consider using discover or dynamic objects?
+// Resources that are not in the Scheme are discovered from the cluster by cluster stores.
 func init() {
 	for _, add := range []func(*apiruntime.Scheme) error{
 		scheme.AddToScheme,
@@ -7,20 +7,68 @@
 //	k8sClass
 //	    Takes string arguments (apiVersion, kind).
 //	    Returns the korrel8r.Class implied by the arguments, or an error.
+//
+//	k8sSelector
+//	    Takes a selector from a resource spec: a metav1.LabelSelector with matchLabels and matchExpressions,
+//	    or a map of labels as used by Service and ReplicationController.
+//	    Returns a label selector string for the `selector` field of a query, or "" for a nil or empty selector.
+//
+//	k8sOwner
+//	    Takes a resource object.
+//	    Returns a Ref to the controller owner of the object, or nil if it has no owner.
+//
+//	k8sTopOwner
+//	    Takes a resource object.
+//	    Follows controller owner references to the top-level owner, for example Pod to ReplicaSet to Deployment.
+//	    Returns a Ref to the top-level owner, or nil if the object has no owner.
+//
+//	k8sOwnedPods
+//	    Takes a resource object.
+//	    Returns a list of Refs to Pods in the same namespace that the object owns, directly or indirectly.
+//
+//	k8sServicePods
+//	    Takes a Service object.
+//	    Returns a list of Refs to Pods that are endpoints of the service, from the service EndpointSlices.
+//
+// A Ref has fields APIVersion, Kind, Namespace and Name, and a Query method that returns a query string.
+// A template can generate one query per line from a list of Refs:
+//
+//	{{range k8sOwnedPods .}}{{.Query}}
+//	{{end}}
+//
+// k8sTopOwner, k8sOwnedPods and k8sServicePods look up objects with the store for the object's cluster,
+// using the user identity of the REST request that applies the rule, or the store credentials if there is none.
+// Each call has a time limit. Objects looked up with the store credentials are cached for a short time.
+// k8sOwnedPods lists pods matching the owner's selector, it returns no pods for an owner without a selector,
+// except for a CronJob, which owns the pods of its Jobs.
 package k8s
 
 import (
+	"context"
+	"fmt"
+	"maps"
+
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // TemplateFuncs for this domain. See package description.
-func (domain) TemplateFuncs() map[string]any {
-	return map[string]any{
-		"k8sClass": k8sClass,
+func (d domain) TemplateFuncs() map[string]any {
+	funcs := map[string]any{
+		"k8sClass":    k8sClass,
+		"k8sSelector": k8sSelector,
+		"k8sOwner":    k8sOwner,
 	}
+	maps.Copy(funcs, d.TemplateFuncsContext(context.Background()))
+	return funcs
 }
 
+// TemplateFuncsContext returns template functions that look up objects using ctx.
+// They replace the functions from TemplateFuncs when a rule is applied with a context.
+func (domain) TemplateFuncsContext(ctx context.Context) map[string]any { return topologyFuncs(ctx) }
+
 // kindToResource convert a kind and apiVersion to a resource string.
 func kindToResource(restMapper meta.RESTMapper, kind string, apiVersion string) (resource string, err error) {
 	gv, err := schema.ParseGroupVersion(apiVersion)
@@ -38,3 +86,25 @@ func kindToResource(restMapper meta.RESTMapper, kind string, apiVersion string)
 func k8sClass(apiVersion, kind string) Class {
 	return Class(schema.FromAPIVersionAndKind(apiVersion, kind))
 }
+
+func k8sSelector(selector any) (string, error) {
+	switch s := selector.(type) {
+	case nil:
+		return "", nil
+	case *metav1.LabelSelector:
+		if s == nil {
+			return "", nil
+		}
+		ls, err := metav1.LabelSelectorAsSelector(s)
+		if err != nil {
+			return "", err
+		}
+		return ls.String(), nil
+	case metav1.LabelSelector:
+		return k8sSelector(&s)
+	case map[string]string:
+		return labels.SelectorFromSet(s).String(), nil
+	default:
+		return "", fmt.Errorf("k8sSelector: invalid selector type %T", selector)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestKindToResource(t *testing.T) {
@@ -26,3 +27,30 @@ func TestKindToResource(t *testing.T) {
 	_, err := kindToResource(rm, "x", "y")
 	assert.EqualError(t, err, "no matches for kind \"x\" in version \"y\"")
 }
+
+func TestK8sSelector(t *testing.T) {
+	for _, x := range []struct {
+		selector any
+		want     string
+	}{
+		{nil, ""},
+		{(*metav1.LabelSelector)(nil), ""},
+		{&metav1.LabelSelector{}, ""},
+		{map[string]string{"b": "2", "a": "1"}, "a=1,b=2"},
+		{metav1.LabelSelector{MatchLabels: map[string]string{"a": "1"}}, "a=1"},
+		{&metav1.LabelSelector{
+			MatchLabels: map[string]string{"a": "1"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "b", Operator: metav1.LabelSelectorOpIn, Values: []string{"x", "y"}},
+				{Key: "c", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"z"}},
+				{Key: "d", Operator: metav1.LabelSelectorOpExists},
+			}}, "a=1,b in (x,y),c notin (z),d"},
+	} {
+		got, err := k8sSelector(x.selector)
+		if assert.NoError(t, err) {
+			assert.Equal(t, x.want, got)
+		}
+	}
+	_, err := k8sSelector("a=1")
+	assert.EqualError(t, err, "k8sSelector: invalid selector type string")
+}
@@ -0,0 +1,375 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Ref refers to a resource found by a topology template function.
+type Ref struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// Class of the referenced resource.
+func (r Ref) Class() Class { return k8sClass(r.APIVersion, r.Kind) }
+
+// Query returns a query string for the referenced resource.
+func (r Ref) Query() string { return NewQuery(r.Class(), r.Namespace, r.Name, nil, nil).String() }
+
+func ownerRef(o client.Object, owner *metav1.OwnerReference) Ref {
+	return Ref{APIVersion: owner.APIVersion, Kind: owner.Kind, Namespace: o.GetNamespace(), Name: owner.Name}
+}
+
+// Limits for topology lookups.
+const (
+	topologyTTL        = 30 * time.Second // Looked-up objects are fetched again after this time.
+	topologyTimeout    = 10 * time.Second // Time limit for a single template function call.
+	maxTopologyObjects = 1000
+	maxOwnerDepth      = 10 // Guard against ownerReference loops.
+)
+
+// topology looks up related objects for template functions, using the [Store] for the cluster of an object.
+//
+// Lookups use the user identity of a REST request if there is one, or the store credentials.
+// Looked-up objects are cached, and only returned from the cache to users that are allowed to get them.
+var topology = &topologyStores{objects: map[string]topologyObject{}}
+
+type topologyStores struct {
+	lock    sync.Mutex
+	stores  []*Store
+	objects map[string]topologyObject // Cached objects, nil for objects that were not found.
+}
+
+type topologyObject struct {
+	object  client.Object
+	expires time.Time
+}
+
+func (t *topologyStores) addStore(s *Store) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stores = append(t.stores, s)
+}
+
+func (t *topologyStores) removeStore(s *Store) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stores = slices.DeleteFunc(t.stores, func(s2 *Store) bool { return s2 == s })
+}
+
+// store returns the most recently created store for the cluster of o.
+func (t *topologyStores) store(o client.Object) (*Store, error) {
+	cluster := o.GetAnnotations()[ClusterAnnotation]
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i := len(t.stores) - 1; i >= 0; i-- {
+		if t.stores[i].cluster == cluster {
+			return t.stores[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no k8s store for cluster %q", cluster)
+}
+
+// get returns the referenced object, or nil if it does not exist.
+func (t *topologyStores) get(ctx context.Context, s *Store, r Ref) (client.Object, error) {
+	q := NewQuery(r.Class(), r.Namespace, r.Name, nil, nil)
+	user, err := s.identities.identity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Only share objects with users that are allowed to get them.
+	shared := user == nil || user.can(ctx, "get", q.GVK(), r.Namespace)
+	key := fmt.Sprintf("%p/%v", s, strings.Join([]string{r.APIVersion, r.Kind, r.Namespace, r.Name}, "/"))
+	now := time.Now()
+	if shared {
+		t.lock.Lock()
+		e, ok := t.objects[key]
+		t.lock.Unlock()
+		if ok && now.Before(e.expires) {
+			return e.object, nil
+		}
+	}
+	o, _ := newObject(q.GVK()).(client.Object)
+	if o == nil {
+		return nil, fmt.Errorf("invalid client.Object: %v", q.GVK())
+	}
+	reader, err := s.reader(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if err := reader.Get(ctx, NamespacedName(r.Namespace, r.Name), o); errors.IsNotFound(err) {
+		o = nil
+	} else if err != nil {
+		return nil, err
+	}
+	if shared {
+		t.add(key, topologyObject{object: o, expires: now.Add(topologyTTL)}, now)
+	}
+	return o, nil
+}
+
+// add an object, evicting expired objects, or an arbitrary object, if the cache is full.
+func (t *topologyStores) add(key string, e topologyObject, now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.objects) >= maxTopologyObjects {
+		for k, v := range t.objects {
+			if now.After(v.expires) {
+				delete(t.objects, k)
+			}
+		}
+		for k := range t.objects {
+			if len(t.objects) < maxTopologyObjects {
+				break
+			}
+			delete(t.objects, k)
+		}
+	}
+	t.objects[key] = e
+}
+
+// list objects of a class in namespace, with optional label selector.
+func (t *topologyStores) list(ctx context.Context, s *Store, list client.ObjectList, class Class, namespace string, selector labels.Selector) error {
+	reader, err := s.reader(ctx, NewQuery(class, namespace, "", nil, nil))
+	if err != nil {
+		return err
+	}
+	opts := []client.ListOption{client.InNamespace(namespace)}
+	if selector != nil {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	return reader.List(ctx, list, opts...)
+}
+
+// owners calls f for each controller owner of o, starting with the closest, until f returns false.
+// The last owner may not exist if the lookup was not allowed or the owner was deleted.
+func (t *topologyStores) owners(ctx context.Context, s *Store, o client.Object, f func(Ref, *metav1.OwnerReference) bool) error {
+	for i := 0; i < maxOwnerDepth && o != nil; i++ {
+		owner := controllerOf(o)
+		if owner == nil {
+			return nil
+		}
+		r := ownerRef(o, owner)
+		if !f(r, owner) {
+			return nil
+		}
+		var err error
+		if o, err = t.get(ctx, s, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// controllerOf returns the controller owner reference of o, or the first owner if there is no controller.
+func controllerOf(o client.Object) *metav1.OwnerReference {
+	if owner := metav1.GetControllerOfNoCopy(o); owner != nil {
+		return owner
+	}
+	if refs := o.GetOwnerReferences(); len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+// isOwner returns true if ref refers to o. Compares UIDs if o has one, otherwise group, kind and name.
+func isOwner(ref *metav1.OwnerReference, o client.Object) bool {
+	if o.GetUID() != "" {
+		return ref.UID == o.GetUID()
+	}
+	gv, _ := schema.ParseGroupVersion(ref.APIVersion)
+	gvk := GroupVersionKind(o)
+	return gv.Group == gvk.Group && ref.Kind == gvk.Kind && ref.Name == o.GetName()
+}
+
+func k8sOwner(o client.Object) *Ref {
+	if owner := controllerOf(o); owner != nil {
+		r := ownerRef(o, owner)
+		return &r
+	}
+	return nil
+}
+
+// topologyFuncs returns the topology template functions, looking up objects with ctx.
+// Each call is limited to topologyTimeout.
+func topologyFuncs(ctx context.Context) map[string]any {
+	return map[string]any{
+		"k8sTopOwner": func(o client.Object) (*Ref, error) {
+			ctx, cancel := context.WithTimeout(ctx, topologyTimeout)
+			defer cancel()
+			return k8sTopOwner(ctx, o)
+		},
+		"k8sOwnedPods": func(o client.Object) ([]Ref, error) {
+			ctx, cancel := context.WithTimeout(ctx, topologyTimeout)
+			defer cancel()
+			return k8sOwnedPods(ctx, o)
+		},
+		"k8sServicePods": func(o client.Object) ([]Ref, error) {
+			ctx, cancel := context.WithTimeout(ctx, topologyTimeout)
+			defer cancel()
+			return k8sServicePods(ctx, o)
+		},
+	}
+}
+
+func k8sTopOwner(ctx context.Context, o client.Object) (*Ref, error) {
+	s, err := topology.store(o)
+	if err != nil {
+		return nil, err
+	}
+	var top *Ref
+	err = topology.owners(ctx, s, o, func(r Ref, _ *metav1.OwnerReference) bool { top = &r; return true })
+	return top, err
+}
+
+// k8sOwnedPods lists pods matching the selector of o, and keeps those that o owns.
+// Owner lookups are remembered for the duration of the call, by owner UID.
+// A CronJob has no selector, its owned pods are the pods owned by its jobs.
+func k8sOwnedPods(ctx context.Context, o client.Object) ([]Ref, error) {
+	s, err := topology.store(o)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := o.(*batchv1.CronJob); ok {
+		return cronJobPods(ctx, s, o)
+	}
+	selector, err := podSelector(o)
+	if err != nil || selector == nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := topology.list(ctx, s, pods, ClassOf(&corev1.Pod{}), o.GetNamespace(), selector); err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	ownedBy := map[types.UID]bool{} // Owner UID: true if o is the owner or one of its owners.
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		owned, known := false, false
+		var walked []types.UID
+		err := topology.owners(ctx, s, pod, func(_ Ref, owner *metav1.OwnerReference) bool {
+			if owned, known = ownedBy[owner.UID]; known {
+				return false // Pods usually share owners, don't look them up again.
+			}
+			if owned = isOwner(owner, o); !owned && owner.UID != "" {
+				walked = append(walked, owner.UID)
+			}
+			return !owned
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range walked {
+			ownedBy[uid] = owned
+		}
+		if owned {
+			refs = append(refs, Ref{APIVersion: "v1", Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
+		}
+	}
+	return refs, nil
+}
+
+// cronJobPods returns the pods owned by jobs that cj owns.
+func cronJobPods(ctx context.Context, s *Store, cj client.Object) ([]Ref, error) {
+	jobs := &batchv1.JobList{}
+	if err := topology.list(ctx, s, jobs, ClassOf(&batchv1.Job{}), cj.GetNamespace(), nil); err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if owner := controllerOf(job); owner == nil || !isOwner(owner, cj) {
+			continue
+		}
+		pods, err := k8sOwnedPods(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, pods...)
+	}
+	return refs, nil
+}
+
+// podSelector returns the pod selector from the spec of o, or nil if o has no selector.
+// An empty selector is treated as no selector, it would select every pod in the namespace.
+func podSelector(o client.Object) (labels.Selector, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+	if err != nil {
+		return nil, err
+	}
+	m, _, _ := unstructured.NestedFieldNoCopy(u, "spec", "selector")
+	sel, _ := m.(map[string]any)
+	if len(sel) == 0 {
+		return nil, nil
+	}
+	var selector labels.Selector
+	if _, ok := o.(*corev1.ReplicationController); ok { // Map of labels, not a LabelSelector.
+		set := labels.Set{}
+		for k, v := range sel {
+			set[k] = fmt.Sprint(v)
+		}
+		selector = labels.SelectorFromSet(set)
+	} else {
+		ls := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(sel, ls); err != nil {
+			return nil, err
+		}
+		if selector, err = metav1.LabelSelectorAsSelector(ls); err != nil {
+			return nil, err
+		}
+	}
+	if selector.Empty() {
+		return nil, nil
+	}
+	return selector, nil
+}
+
+func k8sServicePods(ctx context.Context, o client.Object) ([]Ref, error) {
+	s, err := topology.store(o)
+	if err != nil {
+		return nil, err
+	}
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: o.GetName()})
+	if err := topology.list(ctx, s, endpointSlices, ClassOf(&discoveryv1.EndpointSlice{}), o.GetNamespace(), selector); err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	seen := map[Ref]bool{}
+	for _, slice := range endpointSlices.Items {
+		for _, ep := range slice.Endpoints {
+			if t := ep.TargetRef; t != nil && t.Kind == "Pod" && (t.APIVersion == "" || t.APIVersion == "v1") {
+				r := Ref{APIVersion: "v1", Kind: "Pod", Namespace: t.Namespace, Name: t.Name}
+				if r.Namespace == "" {
+					r.Namespace = slice.Namespace
+				}
+				if !seen[r] {
+					seen[r] = true
+					refs = append(refs, r)
+				}
+			}
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,195 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/rest/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newTopologyStore(t *testing.T, cluster string, objects ...client.Object) *Store {
+	t.Helper()
+	c := fake.NewClientBuilder().WithScheme(Scheme).WithObjects(objects...).Build()
+	s, err := NewStore(c, &rest.Config{})
+	require.NoError(t, err)
+	s.(*Store).cluster = cluster
+	t.Cleanup(func() { _ = s.(*Store).Close() })
+	return s.(*Store)
+}
+
+func ownedBy[T any, PT interface {
+	Object
+	*T
+}](owner client.Object, namespace, name string) PT {
+	o := New[T, PT](namespace, name)
+	o.SetUID(types.UID(name + "-uid"))
+	o.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(owner, GroupVersionKind(owner))})
+	return o
+}
+
+func TestTopology_Owners(t *testing.T) {
+	cj := New[batchv1.CronJob]("ns", "cj")
+	cj.UID = "cj-uid"
+	job := ownedBy[batchv1.Job](cj, "ns", "job")
+	job.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}}
+	pod := ownedBy[corev1.Pod](job, "ns", "pod")
+	pod.Labels = map[string]string{"app": "x"}
+	other := New[corev1.Pod]("ns", "other")
+	other.Labels = map[string]string{"app": "x"} // Selected but not owned.
+	orphan := ownedBy[corev1.Pod](New[appsv1.ReplicaSet]("ns", "missing"), "ns", "orphan")
+	noSelector := New[appsv1.ReplicaSet]("ns", "no-selector")
+	newTopologyStore(t, "", cj, job, pod, other, orphan, noSelector)
+	ctx := context.Background()
+
+	assert.Equal(t, &Ref{APIVersion: "batch/v1", Kind: "Job", Namespace: "ns", Name: "job"}, k8sOwner(pod))
+	assert.Nil(t, k8sOwner(other))
+
+	top, err := k8sTopOwner(ctx, pod)
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "batch/v1", Kind: "CronJob", Namespace: "ns", Name: "cj"}, top)
+	assert.Equal(t, `k8s:CronJob.v1.batch:{"namespace":"ns","name":"cj"}`, top.Query())
+
+	top, err = k8sTopOwner(ctx, orphan) // Owner does not exist.
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "ns", Name: "missing"}, top)
+
+	top, err = k8sTopOwner(ctx, other)
+	require.NoError(t, err)
+	assert.Nil(t, top)
+
+	for _, o := range []client.Object{cj, job} {
+		pods, err := k8sOwnedPods(ctx, o)
+		require.NoError(t, err)
+		assert.Equal(t, []Ref{{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "pod"}}, pods)
+	}
+	pods, err := k8sOwnedPods(ctx, noSelector)
+	require.NoError(t, err)
+	assert.Empty(t, pods)
+}
+
+func TestTopology_Context(t *testing.T) {
+	d := New[appsv1.Deployment]("ns", "d")
+	rs := ownedBy[appsv1.ReplicaSet](d, "ns", "rs")
+	pod := ownedBy[corev1.Pod](rs, "ns", "pod")
+	s := newTopologyStore(t, "", d, rs)
+	s.c = interceptor.NewClient(s.c.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, o client.Object, opts ...client.GetOption) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			return ctx.Err()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := topologyFuncs(ctx)["k8sTopOwner"].(func(client.Object) (*Ref, error))(pod)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	top, err := topologyFuncs(context.Background())["k8sTopOwner"].(func(client.Object) (*Ref, error))(pod)
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "ns", Name: "rs"}, top)
+}
+
+func TestTopology_OwnedPods_lookupOnce(t *testing.T) {
+	d := New[appsv1.Deployment]("ns", "d")
+	d.UID = "d-uid"
+	d.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}}
+	rs := ownedBy[appsv1.ReplicaSet](d, "ns", "rs")
+	objects := []client.Object{d, rs}
+	for _, name := range []string{"a", "b", "c"} {
+		pod := ownedBy[corev1.Pod](rs, "ns", name)
+		pod.Labels = map[string]string{"app": "x"}
+		objects = append(objects, pod)
+	}
+	s := newTopologyStore(t, "", objects...)
+	// Lookups for a REST user that can't be reviewed are not cached by the store.
+	ic, err := newIdentityClients(&rest.Config{}, nil, config.Store{})
+	require.NoError(t, err)
+	gets := map[string]int{}
+	ic.newClient = func(*rest.Config) (client.Client, error) {
+		return interceptor.NewClient(fake.NewClientBuilder().WithScheme(Scheme).WithObjects(objects...).Build(), interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, o client.Object, opts ...client.GetOption) error {
+				gets[key.Name]++
+				return c.Get(ctx, key, o, opts...)
+			},
+		}), nil
+	}
+	s.identities = ic
+	ctx := auth.Context(&http.Request{Header: http.Header{"Authorization": {"Bearer user-token"}}})
+
+	pods, err := k8sOwnedPods(ctx, d)
+	require.NoError(t, err)
+	assert.Len(t, pods, 3)
+	assert.Equal(t, map[string]int{"rs": 1}, gets, "owner looked up once")
+}
+
+func TestTopology_ServicePods(t *testing.T) {
+	svc := New[corev1.Service]("ns", "svc")
+	endpoints := func(name string, pods ...string) *discoveryv1.EndpointSlice {
+		es := New[discoveryv1.EndpointSlice]("ns", name)
+		es.Labels = map[string]string{discoveryv1.LabelServiceName: "svc"}
+		for _, pod := range pods {
+			es.Endpoints = append(es.Endpoints, discoveryv1.Endpoint{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: pod}})
+		}
+		return es
+	}
+	unrelated := endpoints("other-abc", "z")
+	unrelated.Labels[discoveryv1.LabelServiceName] = "other"
+	newTopologyStore(t, "", svc, endpoints("svc-abc", "x", "y"), endpoints("svc-def", "y"), unrelated)
+	ctx := context.Background()
+
+	pods, err := k8sServicePods(ctx, svc)
+	require.NoError(t, err)
+	assert.Equal(t, []Ref{
+		{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "x"},
+		{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "y"},
+	}, pods)
+}
+
+func TestTopology_Cluster(t *testing.T) {
+	rs := New[appsv1.ReplicaSet]("ns", "rs")
+	rs.UID = "rs-uid"
+	d := New[appsv1.Deployment]("ns", "d")
+	rs.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(d, GroupVersionKind(d))}
+	newTopologyStore(t, "east", d)
+	pod := ownedBy[corev1.Pod](rs, "ns", "pod")
+	ctx := context.Background()
+
+	pod.Annotations = map[string]string{ClusterAnnotation: "west"}
+	_, err := k8sTopOwner(ctx, pod)
+	assert.EqualError(t, err, `no k8s store for cluster "west"`)
+
+	pod.Annotations = map[string]string{ClusterAnnotation: "east"}
+	top, err := k8sTopOwner(ctx, pod)
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "ns", Name: "rs"}, top, "rs is not in the east store")
+
+	s := newTopologyStore(t, "east", d, rs) // Most recent store for the cluster is used.
+	top, err = k8sTopOwner(ctx, pod)
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "d"}, top)
+
+	// Looked-up objects are cached.
+	require.NoError(t, s.c.Delete(ctx, rs))
+	top, err = k8sTopOwner(ctx, pod)
+	require.NoError(t, err)
+	assert.Equal(t, &Ref{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "d"}, top)
+}
@@ -42,6 +42,8 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/loki"
@@ -53,12 +55,13 @@ import (
 
 var (
 	// Verify implementing interfaces.
-	_ korrel8r.Domain    = Domain
-	_ korrel8r.Store     = &store{}
-	_ korrel8r.Store     = &stackStore{}
-	_ korrel8r.Query     = Query{}
-	_ korrel8r.Class     = Class("")
-	_ korrel8r.Previewer = Class("")
+	_ korrel8r.Domain       = Domain
+	_ korrel8r.QueryBuilder = Domain
+	_ korrel8r.Store        = &store{}
+	_ korrel8r.Store        = &stackStore{}
+	_ korrel8r.Query        = Query{}
+	_ korrel8r.Class        = Class("")
+	_ korrel8r.Previewer    = Class("")
 )
 
 // Domain for log records produced by openshift-logging.
@@ -84,11 +87,40 @@ func (d domain) Query(s string) (korrel8r.Query, error) {
 	return NewQuery(c.(Class), s), nil
 }
 
+// BuildQuery builds a LogQL stream selector for a mapping rule.
+// Parameters are stream label names, for example `kubernetes_namespace_name`.
+func (d domain) BuildQuery(c korrel8r.Class, params map[string]string) (korrel8r.Query, error) {
+	class, err := impl.TypeAssert[Class](c)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(params))
+	for k := range params {
+		if !labelNameRe.MatchString(k) {
+			return nil, fmt.Errorf("invalid label name for %v query: %q", d, k)
+		}
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	matchers := make([]string, len(names))
+	for i, k := range names {
+		matchers[i] = k + "=" + strconv.Quote(params[k])
+	}
+	return NewQuery(class, "{"+strings.Join(matchers, ",")+"}"), nil
+}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 const (
 	StoreKeyLoki      = "loki"
 	StoreKeyLokiStack = "lokiStack"
 )
 
+// StoreKeys returns the store configuration keys for this domain.
+func (domain) StoreKeys() []string {
+	return append([]string{StoreKeyLoki, StoreKeyLokiStack}, k8s.HTTPStoreKeys...)
+}
+
 func (domain) Store(s any) (korrel8r.Store, error) {
 	cs, err := impl.TypeAssert[config.Store](s)
 	if err != nil {
@@ -7,9 +7,20 @@ import (
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test/domain"
 	"github.com/korrel8r/korrel8r/pkg/domains/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var fixture = domain.Fixture{Query: log.NewQuery(log.Infrastructure, `{kubernetes_namespace_name=~".+"}`)}
 
 func TestLogDomain(t *testing.T)      { fixture.Test(t) }
 func BenchmarLogkDomain(b *testing.B) { fixture.Benchmark(b) }
+
+func TestDomain_BuildQuery(t *testing.T) {
+	got, err := log.Domain.BuildQuery(log.Application, map[string]string{
+		"kubernetes_pod_name": "bar", "kubernetes_namespace_name": `a"b`})
+	require.NoError(t, err)
+	assert.Equal(t, log.NewQuery(log.Application, `{kubernetes_namespace_name="a\"b",kubernetes_pod_name="bar"}`), got)
+	_, err = log.Domain.BuildQuery(log.Application, map[string]string{"k8s.pod": "x"})
+	assert.EqualError(t, err, `invalid label name for log query: "k8s.pod"`)
+}
@@ -65,6 +65,11 @@ func (d domain) Query(s string) (korrel8r.Query, error) {
 
 const StoreKeyMetricURL = "metric"
 
+// StoreKeys returns the store configuration keys for this domain.
+func (domain) StoreKeys() []string {
+	return append([]string{StoreKeyMetricURL}, k8s.HTTPStoreKeys...)
+}
+
 func (domain) Store(s any) (korrel8r.Store, error) {
 	cs, err := impl.TypeAssert[config.Store](s)
 	if err != nil {
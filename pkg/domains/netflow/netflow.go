@@ -86,6 +86,11 @@ const (
 	StoreKeyLokiStack = "lokiStack"
 )
 
+// StoreKeys returns the store configuration keys for this domain.
+func (domain) StoreKeys() []string {
+	return append([]string{StoreKeyLoki, StoreKeyLokiStack}, k8s.HTTPStoreKeys...)
+}
+
 func (domain) Store(s any) (korrel8r.Store, error) {
 	cs, err := impl.TypeAssert[config.Store](s)
 	if err != nil {
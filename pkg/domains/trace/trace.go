@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,10 +34,11 @@ import (
 
 var (
 	// Verify implementing interfaces.
-	_ korrel8r.Domain = Domain
-	_ korrel8r.Store  = &stackStore{}
-	_ korrel8r.Query  = Query("")
-	_ korrel8r.Class  = Class{}
+	_ korrel8r.Domain       = Domain
+	_ korrel8r.QueryBuilder = Domain
+	_ korrel8r.Store        = &stackStore{}
+	_ korrel8r.Query        = Query("")
+	_ korrel8r.Class        = Class{}
 )
 
 var Domain = domain{}
@@ -54,12 +58,37 @@ func (d domain) Query(s string) (korrel8r.Query, error) {
 	return Query(s), nil
 }
 
+// BuildQuery builds a TraceQL attribute filter for a mapping rule.
+// Parameters are scoped TraceQL attribute names, for example `resource.k8s.pod.name`.
+func (d domain) BuildQuery(_ korrel8r.Class, params map[string]string) (korrel8r.Query, error) {
+	names := make([]string, 0, len(params))
+	for k := range params {
+		if !attributeNameRe.MatchString(k) {
+			return nil, fmt.Errorf("invalid attribute name for %v query: %q", d, k)
+		}
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	filters := make([]string, len(names))
+	for i, k := range names {
+		filters[i] = k + "=" + strconv.Quote(params[k])
+	}
+	return NewQuery("{" + strings.Join(filters, "&&") + "}"), nil
+}
+
+var attributeNameRe = regexp.MustCompile(`^(resource|span)?\.[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
 const (
 	StoreKeyTempo       = "tempo"
 	StoreKeyTempoStack  = "tempoStack"
 	StoreKeyTempoTenant = "tenant"
 )
 
+// StoreKeys returns the store configuration keys for this domain.
+func (domain) StoreKeys() []string {
+	return append([]string{StoreKeyTempo, StoreKeyTempoStack, StoreKeyTempoTenant}, k8s.HTTPStoreKeys...)
+}
+
 func (domain) Store(s any) (korrel8r.Store, error) {
 	cs, err := impl.TypeAssert[config.Store](s)
 	if err != nil {
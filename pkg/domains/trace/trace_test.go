@@ -7,6 +7,8 @@ import (
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test/domain"
 	"github.com/korrel8r/korrel8r/pkg/domains/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TODO tempo limits number of traces, not spans. Remove SkipCluster when fixed.
@@ -14,3 +16,12 @@ var fixture = domain.Fixture{Query: trace.NewQuery(`{}`), SkipCluster: true}
 
 func TestTraceDomain(t *testing.T)     { fixture.Test(t) }
 func BenchmarTraceDomain(b *testing.B) { fixture.Benchmark(b) }
+
+func TestDomain_BuildQuery(t *testing.T) {
+	got, err := trace.Domain.BuildQuery(nil, map[string]string{
+		"resource.k8s.pod.name": "bar", "resource.k8s.namespace.name": "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, trace.NewQuery(`{resource.k8s.namespace.name="foo"&&resource.k8s.pod.name="bar"}`), got)
+	_, err = trace.Domain.BuildQuery(nil, map[string]string{"k8s pod": "x"})
+	assert.EqualError(t, err, `invalid attribute name for trace query: "k8s pod"`)
+}
@@ -8,7 +8,11 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"text/template"
 
 	"maps"
@@ -23,8 +27,9 @@ import (
 // Builder initializes the state of an engine.
 // Engine() returns the immutable engine instance.
 type Builder struct {
-	e   *Engine
-	err error
+	e           *Engine
+	err         error
+	diagnostics []rules.Diagnostic
 }
 
 func Build() *Builder {
@@ -32,9 +37,14 @@ func Build() *Builder {
 		domains:     map[string]korrel8r.Domain{},
 		stores:      map[korrel8r.Domain]*stores{},
 		rulesByName: map[string]korrel8r.Rule{},
+		stats:       newStats(),
+		redactors:   map[string]*redactor{},
 	}
 	e.templateFuncs = template.FuncMap{"query": e.query}
 	maps.Copy(e.templateFuncs, sprig.TxtFuncMap())
+	// Rules must not read the korrel8r environment, see storeFuncs.
+	delete(e.templateFuncs, "env")
+	delete(e.templateFuncs, "expandenv")
 	return &Builder{e: e}
 }
 
@@ -48,6 +58,17 @@ func (b *Builder) Domains(domains ...korrel8r.Domain) *Builder {
 			if tf, ok := d.(interface{ TemplateFuncs() map[string]any }); ok {
 				maps.Copy(b.e.templateFuncs, tf.TemplateFuncs())
 			}
+			if tf, ok := d.(interface {
+				TemplateFuncsContext(context.Context) map[string]any
+			}); ok {
+				b.e.contextFuncs = append(b.e.contextFuncs, tf.TemplateFuncsContext)
+			}
+			if dr, ok := d.(interface{ Redactions() []config.Redaction }); ok {
+				// Built-in defaults come first, configured redactions can extend or replace them.
+				redactions := dr.Redactions()
+				b.e.defaultRedact = append(b.e.defaultRedact, redactions...)
+				b.Redactions(redactions...)
+			}
 		default:
 			b.err = fmt.Errorf("Duplicate domain name: %v", d.Name())
 			return b
@@ -73,6 +94,11 @@ func (b *Builder) Stores(stores ...korrel8r.Store) *Builder {
 }
 
 func (b *Builder) StoreConfigs(storeConfigs ...config.Store) *Builder {
+	return b.storeConfigs("", storeConfigs...)
+}
+
+// storeConfigs adds store configurations from a configuration source.
+func (b *Builder) storeConfigs(source string, storeConfigs ...config.Store) *Builder {
 	for _, sc := range storeConfigs {
 		if b.err != nil {
 			return b
@@ -81,7 +107,7 @@ func (b *Builder) StoreConfigs(storeConfigs ...config.Store) *Builder {
 		if b.err != nil {
 			return b
 		}
-		b.err = b.e.stores[d].Add(&store{domain: d, Original: maps.Clone(sc)})
+		b.err = b.e.stores[d].Add(&store{domain: d, Original: maps.Clone(sc), source: source})
 	}
 	return b
 }
@@ -99,10 +125,45 @@ func (b *Builder) Rules(rules ...korrel8r.Rule) *Builder {
 		b.Domains(r.Start()[0].Domain(), r.Goal()[0].Domain())
 		b.e.rulesByName[r.Name()] = r
 		b.e.rules = append(b.e.rules, r)
+		b.e.stats.add(r)
 	}
 	return b
 }
 
+// Redactions adds redactions for objects returned to users, see [Engine.Redact].
+func (b *Builder) Redactions(redactions ...config.Redaction) *Builder {
+	for _, rc := range redactions {
+		if b.err != nil {
+			return b
+		}
+		b.redaction(&rc)
+	}
+	return b
+}
+
+func (b *Builder) redaction(rc *config.Redaction) {
+	d := b.getDomain(rc.Domain)
+	if b.err != nil {
+		return
+	}
+	keys := []string{d.Name()}
+	if len(rc.Classes) > 0 {
+		keys = nil
+		for _, c := range b.classes(&config.ClassSpec{Domain: rc.Domain, Classes: rc.Classes}) {
+			keys = append(keys, c.String())
+		}
+	}
+	for _, key := range keys {
+		if b.e.redactors[key] == nil {
+			b.e.redactors[key] = &redactor{}
+		}
+		if b.err = b.e.redactors[key].add(rc); b.err != nil {
+			b.err = fmt.Errorf("redact %v: %w", rc.Domain, b.err)
+			return
+		}
+	}
+}
+
 // Config an engine.Builder.
 func (b *Builder) Config(configs config.Configs) *Builder {
 	if b.err != nil {
@@ -127,11 +188,35 @@ func (b *Builder) ConfigFile(file string) *Builder {
 	return b.Config(cfg)
 }
 
+// Tuning sets the tuning for stores of each domain in t.Domains.
+func (b *Builder) Tuning(t *config.Tuning) *Builder {
+	if t == nil {
+		return b
+	}
+	for name, dt := range t.Domains {
+		if b.err != nil {
+			return b
+		}
+		d := b.getDomain(name)
+		if b.err != nil {
+			b.err = fmt.Errorf("tuning: %w", b.err)
+			return b
+		}
+		b.e.stores[d].tuning = dt
+	}
+	return b
+}
+
 // Engine returns the final engine, which can no longer be modified.
 // The Builder must not be used after calling Engine()
 func (b *Builder) Engine() (*Engine, error) {
 	e := b.e
 	b.e = nil
+	for _, ss := range e.stores {
+		if err := ss.tune(); err != nil && b.err == nil {
+			b.err = err
+		}
+	}
 	// Create all stores to report problems early.
 	for _, ss := range e.stores {
 		ss.Ensure()
@@ -143,26 +228,270 @@ func (b *Builder) config(source string, c *config.Config) {
 	if b.err != nil {
 		return
 	}
-	b.StoreConfigs(c.Stores...)
-	for _, r := range c.Rules {
+	b.storeConfigs(source, c.Stores...)
+	b.Tuning(c.Tuning)
+	b.Redactions(c.Redact...)
+	for i := range c.Rules {
 		if b.err != nil {
 			return
 		}
-		start := b.classes(&r.Start)
+		n := len(b.e.rules)
+		b.rule(source, &c.Rules[i])
 		if b.err != nil {
 			return
 		}
-		goal := b.classes(&r.Goal)
-		if b.err != nil {
+		for _, rule := range b.e.rules[n:] {
+			b.lint(source, rule)
+		}
+	}
+}
+
+// rule adds the rules generated by a rule configuration.
+func (b *Builder) rule(source string, r *config.Rule) {
+	start := b.classes(&r.Start)
+	if b.err != nil {
+		return
+	}
+	goal := b.classes(&r.Goal)
+	if b.err != nil {
+		return
+	}
+	when := b.conditions(r)
+	if b.err != nil {
+		return
+	}
+	opts := []rules.Option{rules.When(when...), rules.Priority(r.Priority, r.Exclusive)}
+	if len(r.Bidirectional) > 0 {
+		b.bidirectional(r, start, goal)
+		return
+	}
+	if len(r.Result.Mapping) > 0 {
+		if r.Result.Query != "" || r.Result.Plugin != nil {
+			b.err = fmt.Errorf("rule %v: mapping cannot be combined with query or plugin", r.Name)
 			return
 		}
-		var tmpl *template.Template
-		tmpl, b.err = b.e.NewTemplate(r.Name).Parse(r.Result.Query)
+		b.Rules(b.mapping(r.Name, start, goal, r.Result.Mapping, opts))
+		return
+	}
+	if r.Result.Plugin != nil {
+		plugin := b.plugin(source, r)
 		if b.err != nil {
 			return
 		}
-		b.Rules(rules.NewTemplateRule(start, goal, tmpl))
+		b.Rules(rules.NewPluginRule(r.Name, start, goal, plugin, opts...))
+		return
+	}
+	var tmpl *template.Template
+	tmpl, b.err = b.e.NewTemplate(r.Name).Parse(r.Result.Query)
+	if b.err != nil {
+		return
+	}
+	b.Rules(rules.NewTemplateRule(start, goal, tmpl, append(opts, rules.ContextFuncs(b.e.templateFuncsContext))...))
+}
+
+// mapping creates a declarative mapping rule from a map of goal query parameters to start field paths.
+func (b *Builder) mapping(name string, start, goal []korrel8r.Class, mapping map[string]string, opts []rules.Option) (rule korrel8r.Rule) {
+	if b.err != nil {
+		return nil
+	}
+	if len(goal) != 1 {
+		b.err = fmt.Errorf("rule %v: mapping rule must have a single goal class", name)
+		return nil
+	}
+	params := map[string]*template.Template{}
+	var optional []string
+	for param, path := range mapping {
+		if p, ok := strings.CutSuffix(path, config.OptionalSuffix); ok {
+			path = p
+			optional = append(optional, param)
+		}
+		var expr string
+		if expr, b.err = rules.FieldExpr(path); b.err != nil {
+			b.err = fmt.Errorf("rule %v: %w", name, b.err)
+			return nil
+		}
+		if params[param], b.err = b.e.NewTemplate(path).Parse("{{" + expr + "}}"); b.err != nil {
+			return nil
+		}
+	}
+	rule, b.err = rules.NewMappingRule(name, start, goal[0], params, append(opts, rules.Optional(optional...))...)
+	return rule
+}
+
+// bidirectional generates a pair of mapping rules in opposite directions from a list of equivalent fields.
+func (b *Builder) bidirectional(r *config.Rule, start, goal []korrel8r.Class) {
+	if r.Result.Query != "" || r.Result.Plugin != nil || len(r.Result.Mapping) > 0 || r.When != nil {
+		b.err = fmt.Errorf("rule %v: bidirectional rule cannot have a result or preconditions", r.Name)
+		return
+	}
+	if len(start) != 1 || len(goal) != 1 {
+		b.err = fmt.Errorf("rule %v: bidirectional rule must have a single start class and a single goal class", r.Name)
+		return
+	}
+	forward, inverse := map[string]string{}, map[string]string{}
+	for _, eq := range r.Bidirectional {
+		forward[eq.Goal.Param] = eq.Start.Field
+		inverse[eq.Start.Param] = eq.Goal.Field
+	}
+	opts := []rules.Option{rules.Priority(r.Priority, r.Exclusive)}
+	forwardRule := b.mapping(r.Name+config.ForwardSuffix, start, goal, forward, opts)
+	inverseRule := b.mapping(r.Name+config.InverseSuffix, goal, start, inverse, opts)
+	if b.err == nil {
+		b.Rules(forwardRule, inverseRule)
+	}
+}
+
+// plugin loads the WebAssembly module for a plugin rule.
+func (b *Builder) plugin(source string, r *config.Rule) rules.Plugin {
+	spec := r.Result.Plugin
+	if r.Result.Query != "" {
+		b.err = fmt.Errorf("rule %v: cannot have both query and plugin", r.Name)
+		return nil
+	}
+	var wasm []byte
+	if wasm, b.err = config.ReadRelative(source, spec.Module); b.err != nil {
+		b.err = fmt.Errorf("rule %v: %w", r.Name, b.err)
+		return nil
+	}
+	limits := rules.PluginLimits{Memory: uint64(spec.MemoryLimit) * 1024 * 1024, Timeout: spec.Timeout.Duration}
+	var plugin rules.Plugin
+	if plugin, b.err = rules.LoadPlugin(context.Background(), r.Name, wasm, limits); b.err != nil {
+		b.err = fmt.Errorf("rule %v: %w", r.Name, b.err)
+	}
+	return plugin
+}
+
+// lint checks a rule template against its start classes and records any problems.
+func (b *Builder) lint(source string, rule korrel8r.Rule) {
+	for _, d := range rules.Lint(rule, b.e.templateFuncs) {
+		d.Source = source
+		log.V(1).Info("Rule lint", "severity", d.Severity, "source", d.Source, "rule", d.Rule, "classes", d.Classes, "message", d.Message)
+		b.diagnostics = append(b.diagnostics, d)
+	}
+}
+
+// Diagnostics returns problems found by statically checking configured rule templates.
+// See [rules.Lint]. Diagnostics do not prevent the engine from being built.
+func (b *Builder) Diagnostics() []rules.Diagnostic { return b.diagnostics }
+
+// conditions returns the preconditions from a rule's when section.
+func (b *Builder) conditions(r *config.Rule) (when []rules.Condition) {
+	if r.When == nil {
+		return nil
+	}
+	if len(r.When.Classes) > 0 {
+		classes := b.classes(&config.ClassSpec{Domain: r.Start.Domain, Classes: r.When.Classes})
+		if b.err != nil {
+			return nil
+		}
+		when = append(when, rules.ClassCondition(classes...))
+	}
+	if len(r.When.Labels) > 0 {
+		when = append(when, rules.LabelCondition(r.When.Labels))
+	}
+	fields := make([]string, 0, len(r.When.Fields))
+	for field := range r.When.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields) // Test in consistent order.
+	for _, field := range fields {
+		var tmpl *template.Template
+		if tmpl, b.err = b.e.NewTemplate(field).Parse("{{" + field + "}}"); b.err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		if pattern := r.When.Fields[field]; pattern != "" {
+			if re, b.err = regexp.Compile(pattern); b.err != nil {
+				return nil
+			}
+		}
+		when = append(when, rules.FieldCondition(tmpl, re))
+	}
+	return when
+}
+
+// Check validates configurations without building an engine or connecting to stores.
+// Unlike [Builder.Config], it does not stop at the first error, it returns all the problems found.
+//
+// Checks store domains and keys, rule names, class names, templates and redactions.
+// Domains used by the configurations must already be added to the Builder.
+func (b *Builder) Check(configs config.Configs) (problems []config.Problem) {
+	ruleNames := map[string]bool{}
+	for i := range configs {
+		c := &configs[i]
+		add := func(section string, j int, err error) {
+			problems = append(problems, config.Problem{Source: c.Source, Line: c.Lines.Section(section, j), Message: err.Error()})
+		}
+		for j, sc := range c.Stores {
+			if err := b.checkStore(sc); err != nil {
+				add("stores", j, err)
+			}
+		}
+		if c.Tuning != nil {
+			for name := range c.Tuning.Domains {
+				if _, err := b.e.DomainErr(name); err != nil {
+					add("tuning", 0, fmt.Errorf("tuning: %w", err))
+				}
+			}
+		}
+		for j, rc := range c.Redact {
+			rb := Build()
+			for _, d := range b.e.domains {
+				rb.Domains(d)
+			}
+			if err := rb.Redactions(rc).err; err != nil {
+				add("redact", j, err)
+			}
+		}
+		for j, r := range c.Rules {
+			if ruleNames[r.Name] {
+				add("rules", j, fmt.Errorf("Duplicate rule name: %v", r.Name))
+				continue
+			}
+			ruleNames[r.Name] = true
+			// Build each rule with a new builder to find all errors.
+			rb := Build()
+			for _, d := range b.e.domains {
+				rb.Domains(d)
+			}
+			rb.config(c.Source, &config.Config{Source: c.Source, Rules: []config.Rule{r}})
+			if rb.err != nil {
+				add("rules", j, fmt.Errorf("rule %v: %w", r.Name, rb.err))
+			}
+		}
+	}
+	return problems
+}
+
+// checkStore checks the domain and keys of a store configuration.
+func (b *Builder) checkStore(sc config.Store) error {
+	d, err := b.e.DomainErr(sc[config.StoreKeyDomain])
+	if err != nil {
+		return err
+	}
+	if _, err := sc.Tuning(); err != nil {
+		return err
+	}
+	keys := StoreKeys(d)
+	if keys == nil {
+		return nil
+	}
+	for k := range sc {
+		if !slices.Contains(config.CommonStoreKeys, k) && !config.MatchStoreKey(keys, k) {
+			return fmt.Errorf("unknown key for %v store: %q, expecting one of %v", d, k, keys)
+		}
+	}
+	return nil
+}
+
+// StoreKeys returns the store configuration keys for a domain, not including [config.CommonStoreKeys].
+// Returns nil if the domain does not declare its store keys with a StoreKeys() method.
+// A key ending in "*" is a prefix, see [config.MatchStoreKey].
+func StoreKeys(d korrel8r.Domain) []string {
+	if sk, ok := d.(interface{ StoreKeys() []string }); ok {
+		return sk.StoreKeys()
 	}
+	return nil
 }
 
 func (b *Builder) classes(spec *config.ClassSpec) []korrel8r.Class {
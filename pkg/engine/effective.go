@@ -0,0 +1,121 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package engine
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+)
+
+// Origin is the location of an item in the configuration.
+type Origin struct {
+	// Source file or URL, see [config.Config.Source], or [BuiltIn] for defaults provided by a domain.
+	Source string `json:"source,omitempty"`
+	// Line number in the source, 0 if not known.
+	Line int `json:"line,omitempty"`
+}
+
+// BuiltIn is the [Origin] source of built-in defaults.
+const BuiltIn = "built-in"
+
+// EffectiveConfig is the configuration in use by an engine,
+// after includes, profiles and aliases have been applied.
+type EffectiveConfig struct {
+	Rules  []EffectiveRule      `json:"rules,omitempty"`
+	Stores []EffectiveStore     `json:"stores,omitempty"`
+	Redact []EffectiveRedaction `json:"redact,omitempty"`
+	Tuning *EffectiveTuning     `json:"tuning,omitempty"`
+}
+
+// EffectiveRule is a rule configuration with start and goal classes expanded.
+type EffectiveRule struct {
+	config.Rule
+	Origin Origin `json:"origin"`
+}
+
+// EffectiveStore is a store configuration with templates expanded and secrets redacted.
+type EffectiveStore struct {
+	Store  config.Store `json:"store"`
+	Origin Origin       `json:"origin"`
+}
+
+// EffectiveRedaction is a redaction configuration with classes expanded.
+type EffectiveRedaction struct {
+	config.Redaction
+	Origin Origin `json:"origin"`
+}
+
+// EffectiveTuning is the tuning configuration.
+type EffectiveTuning struct {
+	config.Tuning
+	Origin Origin `json:"origin"`
+}
+
+// EffectiveConfig returns the effective configuration, with the origin of each item.
+// The configs must be the configuration used to build the engine.
+//
+// Stores are created if necessary to expand their templates, see [Engine.StoreConfigsFor].
+func (e *Engine) EffectiveConfig(configs config.Configs) *EffectiveConfig {
+	ec := &EffectiveConfig{}
+	if len(configs) > 0 && configs[0].Tuning != nil {
+		ec.Tuning = &EffectiveTuning{Tuning: *configs[0].Tuning, Origin: Origin{Source: configs[0].Source, Line: configs[0].Lines.Section("tuning", 0)}}
+	}
+	for _, c := range configs {
+		for _, r := range c.Rules {
+			er := EffectiveRule{Rule: r, Origin: Origin{Source: c.Source, Line: c.Lines.Rule(r.Name)}}
+			kr := e.Rule(r.Name)
+			if kr == nil {
+				kr = e.Rule(r.Name + config.ForwardSuffix) // Bidirectional rule.
+			}
+			if kr != nil {
+				er.Start.Classes = classNames(kr.Start())
+				er.Goal.Classes = classNames(kr.Goal())
+			}
+			ec.Rules = append(ec.Rules, er)
+		}
+	}
+	for _, r := range e.defaultRedact {
+		ec.Redact = append(ec.Redact, EffectiveRedaction{Redaction: r, Origin: Origin{Source: BuiltIn}})
+	}
+	for _, c := range configs {
+		for i, r := range c.Redact {
+			ec.Redact = append(ec.Redact, EffectiveRedaction{Redaction: r, Origin: Origin{Source: c.Source, Line: c.Lines.Section("redact", i)}})
+		}
+	}
+	for _, d := range e.Domains() {
+		ss := e.stores[d]
+		if ss == nil {
+			continue
+		}
+		ss.Ensure()
+		for _, s := range ss.stores {
+			ec.Stores = append(ec.Stores, EffectiveStore{Store: ss.config(s), Origin: Origin{Source: s.source, Line: storeLine(configs, s)}})
+		}
+	}
+	return ec
+}
+
+// storeLine returns the line of a store in the configuration it came from, or 0 if not known.
+func storeLine(configs config.Configs, s *store) int {
+	for _, c := range configs {
+		// Line numbers are only correct if no stores were moved by a profile.
+		if c.Source != s.source || c.Lines == nil || len(c.Lines.Sections["stores"]) != len(c.Stores) {
+			continue
+		}
+		if i := slices.IndexFunc(c.Stores, func(sc config.Store) bool { return reflect.DeepEqual(sc, s.Original) }); i >= 0 {
+			return c.Lines.Section("stores", i)
+		}
+	}
+	return 0
+}
+
+func classNames(classes []korrel8r.Class) []string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = c.Name()
+	}
+	return names
+}
@@ -6,7 +6,9 @@ package engine
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"slices"
 	"strings"
 	"text/template"
@@ -17,6 +19,7 @@ import (
 	"github.com/korrel8r/korrel8r/pkg/graph"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r/impl"
+	"github.com/korrel8r/korrel8r/pkg/ptr"
 	"golang.org/x/exp/maps"
 )
 
@@ -28,8 +31,12 @@ type Engine struct {
 	domains       map[string]korrel8r.Domain
 	stores        map[korrel8r.Domain]*stores
 	templateFuncs template.FuncMap
+	contextFuncs  []func(context.Context) map[string]any // Template funcs that use the context of a rule application.
 	rulesByName   map[string]korrel8r.Rule
 	rules         []korrel8r.Rule
+	stats         *stats
+	redactors     map[string]*redactor // Keys are class strings, or domain names for all classes in a domain.
+	defaultRedact []config.Redaction   // Built-in redactions provided by domains.
 }
 
 // Domain returns the named domain or nil if not found.
@@ -68,6 +75,26 @@ func (e *Engine) StoreConfigsFor(d korrel8r.Domain) []config.Store {
 	return nil
 }
 
+// StoreProblems returns a problem for each configured store that failed to connect,
+// with the source of the store configuration.
+func (e *Engine) StoreProblems() (problems []config.Problem) {
+	for _, d := range e.Domains() {
+		ss := e.stores[d]
+		if ss == nil {
+			continue
+		}
+		ss.Ensure()
+		for _, s := range ss.stores {
+			s.lock.Lock()
+			if s.Store == nil && s.Err != nil {
+				problems = append(problems, config.Problem{Source: s.source, Message: fmt.Sprintf("%v store: %v", d, s.Err)})
+			}
+			s.lock.Unlock()
+		}
+	}
+	return problems
+}
+
 // Class parses a full class name and returns the
 func (e *Engine) Class(fullname string) (korrel8r.Class, error) {
 	d, c := impl.ClassSplit(fullname)
@@ -84,12 +111,25 @@ func (e *Engine) DomainClass(domain, class string) (korrel8r.Class, error) {
 		return nil, err
 	}
 	c := d.Class(class)
+	if c == nil && e.ensureStores(d) {
+		c = d.Class(class)
+	}
 	if c == nil {
 		return nil, korrel8r.ClassNotFoundError{Class: class, Domain: d}
 	}
 	return c, nil
 }
 
+// ensureStores connects the stores for a domain, which may discover new classes.
+// Returns true if the domain has stores.
+func (e *Engine) ensureStores(d korrel8r.Domain) bool {
+	if ss := e.stores[d]; ss != nil {
+		ss.Ensure()
+		return true
+	}
+	return false
+}
+
 // Query parses a query string to a query object.
 func (e *Engine) Query(query string) (korrel8r.Query, error) {
 	query = strings.TrimSpace(query)
@@ -101,19 +141,31 @@ func (e *Engine) Query(query string) (korrel8r.Query, error) {
 	if err != nil {
 		return nil, err
 	}
-	return domain.Query(query)
+	q, err := domain.Query(query)
+	if errors.As(err, &korrel8r.ClassNotFoundError{}) && e.ensureStores(domain) {
+		q, err = domain.Query(query)
+	}
+	return q, err
 }
 
 func (e *Engine) Rules() []korrel8r.Rule { return slices.Clone(e.rules) }
 
 func (e *Engine) Rule(name string) korrel8r.Rule { return e.rulesByName[name] }
 
+// KeepStats copies rule statistics from an old engine that this engine replaces,
+// for rules with the same name in both engines. Call it before using this engine.
+func (e *Engine) KeepStats(old *Engine) { e.stats.keep(old.stats) }
+
+// RuleStats returns a snapshot of cumulative statistics for every rule, by rule name.
+// Rules that have never been applied have zero statistics.
+func (e *Engine) RuleStats() map[string]RuleStats { return e.stats.get() }
+
 // Graph creates a new graph of the engine's rules.
 func (e *Engine) Graph() *graph.Graph { return graph.NewData(e.Rules()...).FullGraph() }
 
 // Get results for query from all stores for the query domain.
+// Unset constraint values are filled in for each store from its tuning, see [config.StoreTuning].
 func (e *Engine) Get(ctx context.Context, query korrel8r.Query, constraint *korrel8r.Constraint, result korrel8r.Appender) (err error) {
-	constraint = constraint.Default()
 	if timeout := constraint.GetTimeout(); timeout > 0 {
 		var cancel func()
 		ctx, cancel = context.WithTimeout(ctx, timeout)
@@ -143,9 +195,30 @@ func (e *Engine) Get(ctx context.Context, query korrel8r.Query, constraint *korr
 	return ss.Get(ctx, query, constraint, r)
 }
 
+// Close stores and rules that implement [io.Closer]. The engine should not be used after Close.
+// For example, plugin rules release their runtime.
+func (e *Engine) Close() {
+	for _, ss := range e.stores {
+		ss.Close()
+	}
+	for _, r := range e.rules {
+		if c, ok := r.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
 // Follower creates a follower. Constraint can be nil.
 func (e *Engine) Follower(ctx context.Context, c *korrel8r.Constraint) *Follower {
-	return &Follower{Engine: e, Context: ctx, Constraint: c.Default(), rules: map[appliedRule]graph.Queries{}}
+	// Fix the end time so all queries use the same interval, other values depend on store tuning.
+	c2 := korrel8r.Constraint{}
+	if c != nil {
+		c2 = *c
+	}
+	if c2.End == nil {
+		c2.End = ptr.To(time.Now())
+	}
+	return &Follower{Engine: e, Context: ctx, Constraint: &c2, rules: map[appliedRule]graph.Queries{}, clusters: map[string]map[string]bool{}}
 }
 
 // Start populates the start node with objects and results of queries.
@@ -187,15 +260,29 @@ func (e *Engine) Neighbours(ctx context.Context, start korrel8r.Class, objects [
 
 // query implements the template function.
 func (e *Engine) query(query string) ([]korrel8r.Object, error) {
+	return e.queryContext(context.Background(), query)
+}
+
+func (e *Engine) queryContext(ctx context.Context, query string) ([]korrel8r.Object, error) {
 	q, err := e.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	results := korrel8r.NewResult(q.Class())
-	err = e.Get(context.Background(), q, nil, results)
+	err = e.Get(ctx, q, nil, results)
 	return results.List(), err
 }
 
+// templateFuncsContext returns template functions that use ctx, to replace functions in rule templates,
+// see [korrel8r.ContextRule].
+func (e *Engine) templateFuncsContext(ctx context.Context) template.FuncMap {
+	funcs := template.FuncMap{"query": func(query string) ([]korrel8r.Object, error) { return e.queryContext(ctx, query) }}
+	for _, f := range e.contextFuncs {
+		maps.Copy(funcs, f(ctx))
+	}
+	return funcs
+}
+
 // NewTemplate returns a template set up with options and funcs for this engine.
 // See package documentation for more.
 func (e *Engine) NewTemplate(name string) *template.Template {
@@ -204,14 +291,14 @@ func (e *Engine) NewTemplate(name string) *template.Template {
 	return template.New(name).Funcs(e.templateFuncs).Option("missingkey=error")
 }
 
-// execTemplate is a convenience to call NewTemplate, execute the template and stringify the result.
-func (e *Engine) execTemplate(tmplString string, data any) (string, error) {
-	tmpl, err := e.NewTemplate(tmplString).Parse(tmplString)
+// expandStore expands a store configuration template, which can also call [storeFuncs].
+func (e *Engine) expandStore(text string) (string, error) {
+	tmpl, err := e.newStoreTemplate(text)
 	if err != nil {
 		return "", err
 	}
 	w := &bytes.Buffer{}
-	if err := tmpl.Execute(w, data); err != nil {
+	if err := tmpl.Execute(w, nil); err != nil {
 		return "", err
 	}
 	return w.String(), nil
@@ -4,14 +4,22 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/config"
 	"github.com/korrel8r/korrel8r/pkg/graph"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/ptr"
+	"github.com/korrel8r/korrel8r/pkg/rules"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -111,6 +119,130 @@ func TestFollower_Traverse(t *testing.T) {
 	})
 }
 
+func TestFollower_TraverseMultiRule(t *testing.T) {
+	d := mock.Domain("mock")
+	s := mock.NewStore(d)
+	a, b, c := d.Class("a"), d.Class("b"), d.Class("c")
+	// One rule generates 2 queries for b and 1 query for c from each start object.
+	e, err := Build().Rules(
+		mock.NewMultiRule("abc", []korrel8r.Class{a}, []korrel8r.Class{b, c}, func(start korrel8r.Object) ([]korrel8r.Query, error) {
+			return []korrel8r.Query{s.NewQuery(b, start), s.NewQuery(b, start.(int)+10), s.NewQuery(c, start.(int)+20)}, nil
+		}),
+	).Stores(s).Engine()
+	require.NoError(t, err)
+	g := e.Graph()
+	g.NodeFor(a).Result.Append(1, 2)
+	f := e.Follower(context.Background(), nil)
+	_, err = g.Traverse(a, []korrel8r.Class{b, c}, f.Traverse)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []korrel8r.Object{1, 2, 11, 12}, g.NodeFor(b).Result.List())
+	assert.ElementsMatch(t, []korrel8r.Object{21, 22}, g.NodeFor(c).Result.List())
+	// Each query is attributed to the line for its goal class.
+	g.EachLine(func(l *graph.Line) {
+		goal := l.To().(*graph.Node).Class
+		for _, qc := range l.Queries {
+			assert.Equal(t, goal, qc.Query.Class(), qc.Query.String())
+		}
+		switch goal {
+		case b:
+			assert.Len(t, l.Queries, 4)
+		case c:
+			assert.Len(t, l.Queries, 2)
+		default:
+			t.Fatalf("unexpected goal: %v", goal)
+		}
+	})
+}
+
+func TestEngine_RuleStats(t *testing.T) {
+	d := mock.Domain("mock")
+	s := mock.NewStore(d)
+	a, b, c := d.Class("a"), d.Class("b"), d.Class("c")
+	newRules := func() []korrel8r.Rule {
+		return []korrel8r.Rule{
+			mock.NewRuleQuery("ab", a, b, s.NewQuery(b, 1, 2)),
+			mock.NewRuleQuery("ab2", a, b, s.NewQuery(b, 1, 2)), // Same query as ab.
+			mock.NewRule("bc", []korrel8r.Class{b}, []korrel8r.Class{c}, func(start korrel8r.Object) (korrel8r.Query, error) {
+				if start.(int) == 1 {
+					return nil, rules.ErrNoQuery // Rule does not apply, not an error.
+				}
+				return s.NewQuery(c), nil // Query with no results.
+			}),
+			mock.NewRule("bcFail", []korrel8r.Class{b}, []korrel8r.Class{c}, func(start korrel8r.Object) (korrel8r.Query, error) {
+				return nil, errors.New("failed")
+			}),
+			mock.NewRuleQuery("ca", c, a, s.NewQuery(a)),
+		}
+	}
+	e, err := Build().Rules(newRules()...).Stores(s).Engine()
+	require.NoError(t, err)
+	_, err = e.GoalSearch(context.Background(), e.Graph(), a, []korrel8r.Object{0}, nil, nil, []korrel8r.Class{c})
+	require.NoError(t, err)
+	stats := e.RuleStats()
+	assert.Positive(t, stats["ab"].Latency)
+	for name, rs := range stats { // Latency varies, clear it for comparison.
+		rs.Latency = 0
+		stats[name] = rs
+	}
+	want := map[string]RuleStats{
+		"ab":     {Applied: 1, Queries: 1, Results: 1, Objects: 2},
+		"ab2":    {Applied: 1, Queries: 1, Cached: 1}, // Results are credited to ab.
+		"bc":     {Applied: 2, NoQuery: 1, Queries: 1},
+		"bcFail": {Applied: 2, Errors: 2},
+		"ca":     {}, // Never applied.
+	}
+	assert.Equal(t, want, stats)
+
+	// A replacement engine keeps statistics for rules with the same name.
+	e2, err := Build().Rules(append(newRules()[1:], mock.NewRuleQuery("new", a, c, nil))...).Stores(s).Engine()
+	require.NoError(t, err)
+	e2.KeepStats(e)
+	stats = e2.RuleStats()
+	for name, rs := range stats {
+		rs.Latency = 0
+		stats[name] = rs
+	}
+	delete(want, "ab")
+	want["new"] = RuleStats{}
+	assert.Equal(t, want, stats)
+}
+
+func TestFollower_TraverseRulePriority(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	s := mock.NewStoreWith(d, mock.QueryMap{
+		"mock:b:primary":  []korrel8r.Object{1},
+		"mock:b:fallback": []korrel8r.Object{2},
+		"mock:b:other":    []korrel8r.Object{3},
+	})
+	rule := func(name, query string, opts ...rules.Option) korrel8r.Rule {
+		return rules.NewTemplateRule([]korrel8r.Class{a}, []korrel8r.Class{b}, template.Must(template.New(name).Parse(query)), opts...)
+	}
+	for _, x := range []struct {
+		name    string
+		primary string
+		want    []korrel8r.Object
+	}{
+		{"primary has results", "mock:b:primary", []korrel8r.Object{1, 3}},
+		{"primary has no results", "mock:b:missing", []korrel8r.Object{2, 3}},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			e, err := Build().Rules(
+				rule("fallback", "mock:b:fallback", rules.Priority(-1, false)),
+				rule("other", "mock:b:other", rules.Priority(1, false)), // Same priority, not suppressed.
+				rule("primary", x.primary, rules.Priority(1, true)),
+			).Stores(s).Engine()
+			require.NoError(t, err)
+			g := e.Graph()
+			g.NodeFor(a).Result.Append(0)
+			f := e.Follower(context.Background(), nil)
+			_, err = g.Traverse(a, []korrel8r.Class{b}, f.Traverse)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, x.want, g.NodeFor(b).Result.List())
+		})
+	}
+}
+
 func (o obj) String() string { return o.Name }
 
 func TestEngine_PropagateConstraints(t *testing.T) {
@@ -184,6 +316,173 @@ func TestEngine_ConfigMockStore(t *testing.T) {
 	assert.Equal(t, []korrel8r.Object{"hello", "there"}, r.List())
 }
 
+func TestEngine_ConfigMappingRule(t *testing.T) {
+	d := mock.Domain("mock")
+	e, err := Build().Domains(d).Config(config.Configs{{
+		Rules: []config.Rule{{
+			Name:   "map",
+			Start:  config.ClassSpec{Domain: "mock", Classes: []string{"a"}},
+			Goal:   config.ClassSpec{Domain: "mock", Classes: []string{"b"}},
+			Result: config.ResultSpec{Mapping: map[string]string{"x": ".X", "y": `.Y["k"]`}},
+		}},
+	}}).Engine()
+	require.NoError(t, err)
+	q, err := e.Rule("map").Apply(map[string]any{"X": "foo", "Y": map[string]any{"k": "bar"}})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(d.Class("b"), "x=foo,y=bar"), q)
+}
+
+func TestEngine_ConfigBidirectionalRule(t *testing.T) {
+	d := mock.Domain("mock")
+	rule := config.Rule{
+		Name:  "ab",
+		Start: config.ClassSpec{Domain: "mock", Classes: []string{"a"}},
+		Goal:  config.ClassSpec{Domain: "mock", Classes: []string{"b"}},
+		Bidirectional: []config.Equivalence{
+			{Start: config.FieldSpec{Field: ".X", Param: "x"}, Goal: config.FieldSpec{Field: ".U", Param: "u"}},
+		},
+	}
+	e, err := Build().Domains(d).Config(config.Configs{{Rules: []config.Rule{rule}}}).Engine()
+	require.NoError(t, err)
+	q, err := e.Rule("ab/forward").Apply(map[string]any{"X": "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(d.Class("b"), "u=foo"), q)
+	q, err = e.Rule("ab/inverse").Apply(map[string]any{"U": "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(d.Class("a"), "x=bar"), q)
+
+	rule.Start.Classes = []string{"a", "c"}
+	_, err = Build().Domains(d).Config(config.Configs{{Rules: []config.Rule{rule}}}).Engine()
+	assert.ErrorContains(t, err, "rule ab: bidirectional rule must have a single start class and a single goal class")
+}
+
+func TestEngine_StoreSecrets(t *testing.T) {
+	t.Setenv("TEST_PLAIN", "plain")
+	t.Setenv("TEST_SECRET", "hush")
+	secretFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cr3t\n"), 0600))
+	d := mock.Domain("mock")
+	e, err := Build().Domains(d).StoreConfigs(config.Store{
+		config.StoreKeyDomain: "mock",
+		config.StoreKeyMock:   "testdata/mock_store.yaml",
+		"plain":               `{{env "TEST_PLAIN"}}`,
+		"file":                fmt.Sprintf(`{{readFile %q}}`, secretFile),
+		"env":                 `{{secretEnv "TEST_SECRET"}}`,
+		"token":               fmt.Sprintf(`Bearer {{if true}}{{secret %q}}{{end}}`, secretFile),
+		"password":            "plain-password",
+		"header.X-Tenant":     "tenant",
+	}).Engine()
+	require.NoError(t, err)
+	ss := e.stores[d].stores
+	require.Len(t, ss, 1)
+	_, err = ss[0].Ensure()
+	require.NoError(t, err)
+	assert.Equal(t, "hush", ss[0].Expanded["env"])
+	assert.Equal(t, "Bearer s3cr3t", ss[0].Expanded["token"])
+	assert.Equal(t, []config.Store{{
+		config.StoreKeyDomain: "mock",
+		config.StoreKeyMock:   "testdata/mock_store.yaml",
+		"plain":               "plain",
+		"file":                Redacted,
+		"env":                 Redacted,
+		"token":               Redacted,
+		"password":            Redacted,
+		"header.X-Tenant":     Redacted,
+	}}, e.StoreConfigsFor(d))
+
+	// Store functions are not available to rules.
+	for _, f := range []string{fmt.Sprintf("readFile %q", secretFile), `env "HOME"`, `expandenv "$HOME"`} {
+		_, err = Build().Domains(d).Config(config.Configs{{
+			Rules: []config.Rule{{
+				Name:   "leak",
+				Start:  config.ClassSpec{Domain: "mock", Classes: []string{"a"}},
+				Goal:   config.ClassSpec{Domain: "mock", Classes: []string{"b"}},
+				Result: config.ResultSpec{Query: "mock:b:{{" + f + "}}"},
+			}},
+		}}).Engine()
+		assert.ErrorContains(t, err, fmt.Sprintf(`function %q not defined`, strings.Fields(f)[0]))
+	}
+}
+
+func TestEngine_StoreProblems(t *testing.T) {
+	d := mock.Domain("mock")
+	e, err := Build().Domains(d).Config(config.Configs{
+		{Source: "good", Stores: []config.Store{{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml"}}},
+		{Source: "bad", Stores: []config.Store{{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/nonesuch.yaml"}}},
+	}).Engine()
+	require.NoError(t, err)
+	problems := e.StoreProblems()
+	require.Len(t, problems, 1)
+	assert.Equal(t, "bad", problems[0].Source)
+	assert.Contains(t, problems[0].Message, "mock store: failed to load mockData")
+}
+
+// constraintStore records the constraint passed to Get.
+type constraintStore struct {
+	korrel8r.Store
+	got *korrel8r.Constraint
+}
+
+func (s *constraintStore) Get(_ context.Context, _ korrel8r.Query, c *korrel8r.Constraint, _ korrel8r.Appender) error {
+	s.got = c
+	return nil
+}
+
+func TestEngine_StoreTuning(t *testing.T) {
+	d := mock.Domain("mock")
+	s := &constraintStore{Store: mock.NewStore(d)}
+	e, err := Build().Domains(d).Stores(s).
+		StoreConfigs(config.Store{config.StoreKeyDomain: "mock", config.StoreKeyLimit: "3", config.StoreKeyMaxConcurrent: "2"}).
+		Tuning(&config.Tuning{Domains: map[string]config.StoreTuning{
+			"mock": {Limit: 5, Lookback: config.Duration{Duration: 10 * time.Minute}},
+		}}).Engine()
+	require.NoError(t, err)
+	q := mock.NewQuery(d.Class("a"), "x")
+
+	require.NoError(t, e.Get(context.Background(), q, nil, korrel8r.NewListResult()))
+	assert.Equal(t, 5, s.got.GetLimit())
+	assert.Equal(t, 10*time.Minute, s.got.GetEnd().Sub(s.got.GetStart()))
+	assert.Equal(t, korrel8r.DefaultTimeout, s.got.GetTimeout())
+
+	end := time.Now()
+	require.NoError(t, e.Get(context.Background(), q, &korrel8r.Constraint{Limit: ptr.To(7), End: &end}, korrel8r.NewListResult()))
+	assert.Equal(t, 7, s.got.GetLimit())
+	assert.Equal(t, end.Add(-10*time.Minute), s.got.GetStart())
+
+	configured := e.stores[d].stores[1]
+	assert.Equal(t, 3, configured.tuning.Limit)
+	assert.Equal(t, 10*time.Minute, configured.tuning.Lookback.Duration)
+	assert.Equal(t, 2, cap(configured.sem))
+
+	_, err = Build().Domains(d).StoreConfigs(config.Store{config.StoreKeyDomain: "mock", config.StoreKeyLimit: "x"}).Engine()
+	assert.EqualError(t, err, `mock store: limit: strconv.ParseInt: parsing "x": invalid syntax`)
+	_, err = Build().Domains(d).Tuning(&config.Tuning{Domains: map[string]config.StoreTuning{"nonesuch": {}}}).Engine()
+	assert.EqualError(t, err, `tuning: domain not found: "nonesuch"`)
+}
+
+// keyedDomain is a mock domain that declares its store keys.
+type keyedDomain struct{ mock.Domain }
+
+func (keyedDomain) StoreKeys() []string { return []string{"url", "header.*"} }
+
+func TestBuilder_Check(t *testing.T) {
+	configs, err := config.Load("testdata/check.yaml")
+	require.NoError(t, err)
+	problems := Build().Domains(mock.NewDomainWithClasses("mock", "a", "b"), keyedDomain{mock.Domain("keyed")}).Check(configs)
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		`testdata/check.yaml:5: unknown key for keyed store: "nonesuch", expecting one of [url header.*]`,
+		`testdata/check.yaml:30: redact mock: invalid field path: "x[]"`,
+		`testdata/check.yaml:32: redact mock: error parsing regexp: missing closing ): ` + "`(`",
+		`testdata/check.yaml:14: rule badclass: class not found in domain mock: "nonesuch"`,
+		`testdata/check.yaml:18: rule badtemplate: template: badtemplate:1: unclosed action`,
+		`testdata/check.yaml:22: Duplicate rule name: good`,
+	}, got)
+}
+
 func asStrings[T any](v []T) []string {
 	s := make([]string, len(v))
 	for i := range v {
@@ -212,3 +511,140 @@ type obj struct {
 	Name string
 	Time time.Time
 }
+
+func TestEngine_EffectiveConfig(t *testing.T) {
+	configs, err := config.Load("testdata/effective.yaml")
+	require.NoError(t, err)
+	e, err := Build().Domains(mock.NewDomainWithClasses("mock", "a", "b", "c")).Config(configs).Engine()
+	require.NoError(t, err)
+	ec := e.EffectiveConfig(configs)
+	origin := func(line int) Origin { return Origin{Source: "testdata/effective.yaml", Line: line} }
+
+	require.Len(t, ec.Rules, 2)
+	assert.Equal(t, []string{"a", "b"}, ec.Rules[0].Start.Classes)
+	assert.Equal(t, origin(12), ec.Rules[0].Origin)
+	assert.Equal(t, []string{"a", "b", "c"}, ec.Rules[1].Start.Classes)
+	assert.Equal(t, origin(16), ec.Rules[1].Origin)
+	assert.Equal(t, []EffectiveStore{{
+		Store:  config.Store{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml", config.StoreKeyPassword: Redacted},
+		Origin: origin(6),
+	}}, ec.Stores)
+	assert.Equal(t, []EffectiveRedaction{{
+		Redaction: config.Redaction{Domain: "mock", Classes: []string{"a", "b"}, Remove: []string{"secret"}},
+		Origin:    origin(21),
+	}}, ec.Redact)
+	require.NotNil(t, ec.Tuning)
+	assert.Equal(t, 10*time.Second, ec.Tuning.RequestTimeout.Duration)
+	assert.Equal(t, origin(9), ec.Tuning.Origin)
+
+	// Line numbers are recorded by config.Load, the source is not read again.
+	configs[0].Lines = nil
+	assert.Equal(t, origin(0), e.EffectiveConfig(configs).Rules[0].Origin)
+}
+
+func TestEngine_StoreCluster(t *testing.T) {
+	d := mock.Domain("mock")
+	e, err := Build().Domains(d).StoreConfigs(
+		config.Store{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml", config.StoreKeyCluster: "east"},
+		config.Store{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml", config.StoreKeyCluster: "west"},
+		config.Store{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml"},
+	).Engine()
+	require.NoError(t, err)
+	q, err := e.Query("mock:foo:hello")
+	require.NoError(t, err)
+	for _, x := range []struct {
+		cluster string
+		want    []korrel8r.Object
+	}{
+		{"", []korrel8r.Object{"hello", "there", "hello", "there", "hello", "there"}},
+		{"east", []korrel8r.Object{"hello", "there"}}, // Store with no cluster name is not used.
+		{"north", nil},
+	} {
+		t.Run(x.cluster, func(t *testing.T) {
+			var result korrel8r.ListResult
+			require.NoError(t, e.Get(context.Background(), q, &korrel8r.Constraint{Cluster: x.cluster}, &result))
+			assert.Equal(t, x.want, []korrel8r.Object(result))
+		})
+	}
+}
+
+func TestFollower_clusters(t *testing.T) {
+	e, err := Build().Domains(mock.Domain("mock")).Engine()
+	require.NoError(t, err)
+	f := e.Follower(context.Background(), nil)
+	q := mock.NewQuery(mock.Domain("mock").Class("a"), "x")
+	clusters := func() (names []string) {
+		for _, c := range f.constraints(q) {
+			names = append(names, c.Cluster)
+		}
+		return names
+	}
+	assert.Equal(t, []string{""}, clusters())
+	f.addCluster(q, "west")
+	f.addCluster(q, "east")
+	assert.Equal(t, []string{"east", "west"}, clusters())
+	f.addCluster(q, "") // A start object with no cluster, search all clusters.
+	assert.Equal(t, []string{""}, clusters())
+}
+
+type closerStore struct {
+	korrel8r.Store
+	closed bool
+}
+
+func (s *closerStore) Close() error { s.closed = true; return nil }
+
+type closerRule struct {
+	korrel8r.Rule
+	closed bool
+}
+
+func (r *closerRule) Close() error { r.closed = true; return nil }
+
+func TestEngine_Close(t *testing.T) {
+	d := mock.Domain("mock")
+	r := &closerRule{Rule: mock.NewRuleQuery("r", d.Class("a"), d.Class("b"), nil)}
+	e, err := Build().Domains(d).Rules(r).StoreConfigs(config.Store{config.StoreKeyDomain: "mock", config.StoreKeyMock: "testdata/mock_store.yaml"}).Engine()
+	require.NoError(t, err)
+	s := e.stores[d].stores[0]
+	ks, err := s.Ensure()
+	require.NoError(t, err)
+	cs := &closerStore{Store: ks}
+	s.Store = cs
+	e.Close()
+	assert.True(t, cs.closed)
+	assert.True(t, r.closed, "rules are closed")
+	assert.Nil(t, s.Store, "re-created on next use")
+}
+
+// discoveringDomain has a class that is only known after a store is created.
+type discoveringDomain struct {
+	mock.Domain
+	discovered *bool
+}
+
+func (d discoveringDomain) Class(name string) korrel8r.Class {
+	if name == "late" && !*d.discovered {
+		return nil
+	}
+	return d.Domain.Class(name)
+}
+
+func (d discoveringDomain) Store(cfg any) (korrel8r.Store, error) {
+	*d.discovered = true
+	return d.Domain.Store(cfg)
+}
+
+func TestEngine_DomainClass_discovered(t *testing.T) {
+	d := discoveringDomain{Domain: "mock", discovered: new(bool)}
+	e, err := Build().Domains(d).StoreConfigs(config.Store{config.StoreKeyDomain: "mock"}).Engine()
+	require.NoError(t, err)
+	// Simulate a store that failed to connect when the engine was built.
+	*d.discovered = false
+	e.stores[d].stores[0].Store = nil
+	c, err := e.DomainClass("mock", "late")
+	require.NoError(t, err)
+	assert.Equal(t, "late", c.Name())
+	_, err = e.DomainClass("mock", "nonesuch")
+	assert.NoError(t, err) // Mock domain accepts any other name.
+}
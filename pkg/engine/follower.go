@@ -4,10 +4,14 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"maps"
+	"slices"
+	"time"
 
 	"github.com/korrel8r/korrel8r/pkg/graph"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/rules"
 )
 
 type appliedRule struct {
@@ -23,6 +27,24 @@ type Follower struct {
 
 	// temporary store for results of rules that need to be saved for a later line.
 	rules map[appliedRule]graph.Queries
+	// clusters of the start objects for each query, "" means no cluster.
+	clusters map[string]map[string]bool
+}
+
+// objectCluster returns the cluster name of an object if the domain provides it, or "".
+func objectCluster(d korrel8r.Domain, o korrel8r.Object) string {
+	if oc, ok := d.(interface{ ObjectCluster(korrel8r.Object) string }); ok {
+		return oc.ObjectCluster(o)
+	}
+	return ""
+}
+
+// withCluster returns a query restricted to a cluster if the query supports it.
+func withCluster(q korrel8r.Query, cluster string) korrel8r.Query {
+	if wc, ok := q.(interface{ WithCluster(string) korrel8r.Query }); ok && cluster != "" {
+		return wc.WithCluster(cluster)
+	}
+	return q
 }
 
 // Traverse a line gets all queries provided by Visit() on the From node,
@@ -37,11 +59,34 @@ func (f *Follower) Traverse(l *graph.Line) bool {
 		f.rules[key] = graph.Queries{}
 		for _, s := range start.Result.List() {
 			count++
-			q, err := rule.Apply(s)
-			if q == nil { // Rule does  not apply
+			if err := korrel8r.RuleApplies(rule, start.Class, s); err != nil {
+				log.V(4).Info("Rule does not apply", "rule", rule.Name(), "reason", err, "id", korrel8r.GetID(start.Class, s))
+				f.Engine.stats.update(rule, func(rs *RuleStats) { rs.Skipped++ })
+				continue
+			}
+			queries, err := korrel8r.ApplyRuleContext(f.Context, rule, s)
+			noQuery := len(queries) == 0 && (err == nil || errors.Is(err, rules.ErrNoQuery))
+			f.Engine.stats.update(rule, func(rs *RuleStats) {
+				rs.Applied++
+				rs.Queries += len(queries)
+				switch {
+				case noQuery:
+					rs.NoQuery++
+				case err != nil:
+					rs.Errors++
+				}
+			})
+			switch {
+			case noQuery: // Rule does not apply
+				log.V(4).Info("Rule generated no query", "rule", rule.Name(), "id", korrel8r.GetID(start.Class, s))
+			case err != nil:
 				log.V(4).Info("Rule apply error", "rule", rule.Name(), "error", err, "id", korrel8r.GetID(start.Class, s))
-			} else {
+			}
+			cluster := objectCluster(start.Class.Domain(), s)
+			for _, q := range queries { // Queries may be for different goals, each is processed by the line for its goal.
+				q = withCluster(q, cluster)
 				f.rules[key].Set(q, -1)
+				f.addCluster(q, cluster)
 				log.V(4).Info("Rule apply", "rule", rule.Name(), "query", q, "id", korrel8r.GetID(start.Class, s))
 			}
 		}
@@ -55,11 +100,16 @@ func (f *Follower) Traverse(l *graph.Line) bool {
 			return false
 		case goal.Queries.Has(q): // Already evaluated on goal node.
 			l.Queries.Set(q, qc.Count) // Record on the link
+			f.Engine.stats.update(rule, func(rs *RuleStats) { rs.Cached++ })
 			return true
 		default: // Evaluate the query and store the results
 			var count int
 			result := korrel8r.FuncAppender(func(o korrel8r.Object) { goal.Result.Append(o); count++ })
-			_ = f.Engine.Get(f.Context, q, f.Constraint, result)
+			begin := time.Now()
+			for _, c := range f.constraints(q) {
+				_ = f.Engine.Get(f.Context, q, c, result)
+			}
+			f.recordResult(rule, count, time.Since(begin))
 			l.Queries.Set(q, count)
 			goal.Queries.Set(q, count)
 			return true
@@ -68,3 +118,45 @@ func (f *Follower) Traverse(l *graph.Line) bool {
 
 	return l.Queries.Total() > 0
 }
+
+// recordResult records the result count and latency of a query generated by rule.
+func (f *Follower) recordResult(rule korrel8r.Rule, count int, latency time.Duration) {
+	f.Engine.stats.update(rule, func(rs *RuleStats) {
+		if count > 0 {
+			rs.Results++
+			rs.Objects += count
+		}
+		rs.Latency += latency
+	})
+}
+
+func (f *Follower) addCluster(q korrel8r.Query, cluster string) {
+	if f.clusters == nil {
+		f.clusters = map[string]map[string]bool{}
+	}
+	if f.clusters[q.String()] == nil {
+		f.clusters[q.String()] = map[string]bool{}
+	}
+	f.clusters[q.String()][cluster] = true
+}
+
+// constraints returns the constraints to evaluate a query: one per cluster of the start objects
+// that generated the query, or the follower constraint if any start object has no cluster.
+func (f *Follower) constraints(q korrel8r.Query) []*korrel8r.Constraint {
+	clusters := f.clusters[q.String()]
+	if len(clusters) == 0 || clusters[""] || f.Constraint == nil || f.Constraint.Cluster != "" {
+		return []*korrel8r.Constraint{f.Constraint}
+	}
+	names := make([]string, 0, len(clusters))
+	for cluster := range clusters {
+		names = append(names, cluster)
+	}
+	slices.Sort(names)
+	constraints := make([]*korrel8r.Constraint, len(names))
+	for i, cluster := range names {
+		c := *f.Constraint
+		c.Cluster = cluster
+		constraints[i] = &c
+	}
+	return constraints
+}
@@ -0,0 +1,164 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+)
+
+// redactor removes and masks fields of objects, see [config.Redaction].
+type redactor struct {
+	remove [][]pathElement
+	mask   []*regexp.Regexp
+}
+
+// pathElement is a map key, or every element of a list.
+type pathElement struct {
+	key  string
+	each bool
+}
+
+// add a redaction configuration to the redactor.
+func (r *redactor) add(rc *config.Redaction) error {
+	if rc.Replace {
+		r.remove, r.mask = nil, nil
+	}
+	for _, path := range rc.Remove {
+		elements, err := parsePath(path)
+		if err != nil {
+			return err
+		}
+		r.remove = append(r.remove, elements)
+	}
+	for _, pattern := range rc.Mask {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		r.mask = append(r.mask, re)
+	}
+	return nil
+}
+
+// parsePath parses a field path like `spec.containers[].env[].value` or `metadata.annotations["a.b/c"]`.
+// The last element must be a map key.
+func parsePath(path string) (elements []pathElement, err error) {
+	s := path
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, "[]"):
+			elements = append(elements, pathElement{each: true})
+			s = s[2:]
+		case strings.HasPrefix(s, `["`):
+			end := strings.Index(s[2:], `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid field path: %q", path)
+			}
+			elements = append(elements, pathElement{key: s[2 : 2+end]})
+			s = s[end+4:]
+		default:
+			s = strings.TrimPrefix(s, ".")
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid field path: %q", path)
+			}
+			elements = append(elements, pathElement{key: s[:end]})
+			s = s[end:]
+		}
+	}
+	if len(elements) == 0 || elements[len(elements)-1].each {
+		return nil, fmt.Errorf("invalid field path: %q", path)
+	}
+	return elements, nil
+}
+
+// redact a generic JSON value in place, returns the redacted value.
+func (r *redactor) redact(v any) any {
+	for _, path := range r.remove {
+		removePath(v, path)
+	}
+	if len(r.mask) > 0 {
+		v = r.maskValue(v)
+	}
+	return v
+}
+
+func removePath(v any, path []pathElement) {
+	switch {
+	case len(path) == 0:
+	case path[0].each:
+		if list, ok := v.([]any); ok {
+			for _, x := range list {
+				removePath(x, path[1:])
+			}
+		}
+	default:
+		if m, ok := v.(map[string]any); ok {
+			if len(path) == 1 {
+				delete(m, path[0].key)
+			} else {
+				removePath(m[path[0].key], path[1:])
+			}
+		}
+	}
+}
+
+func (r *redactor) maskValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		for _, re := range r.mask {
+			v = re.ReplaceAllLiteralString(v, Redacted)
+		}
+		return v
+	case map[string]any:
+		for k, x := range v {
+			v[k] = r.maskValue(x)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = r.maskValue(x)
+		}
+	}
+	return v
+}
+
+// Redact returns an object with the fields removed or masked by the configured redactions for its class,
+// see [config.Redaction]. Call Redact for objects returned to users, rules use unredacted objects.
+//
+// Returns o unchanged if there are no redactions for the class.
+// Otherwise returns a copy of o as generic JSON values: maps, lists, strings, numbers and booleans.
+func (e *Engine) Redact(class korrel8r.Class, o korrel8r.Object) (any, error) {
+	var redactors []*redactor
+	for _, key := range []string{class.Domain().Name(), class.String()} {
+		if r := e.redactors[key]; r != nil {
+			redactors = append(redactors, r)
+		}
+	}
+	if len(redactors) == 0 {
+		return o, nil
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber() // Don't change numbers
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	for _, r := range redactors {
+		v = r.redact(v)
+	}
+	return v, nil
+}
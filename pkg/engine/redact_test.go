@@ -0,0 +1,126 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/must"
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	for _, x := range []struct {
+		path string
+		want []pathElement
+	}{
+		{"data", []pathElement{{key: "data"}}},
+		{".spec.containers[].env[].value", []pathElement{{key: "spec"}, {key: "containers"}, {each: true}, {key: "env"}, {each: true}, {key: "value"}}},
+		{`metadata.annotations["a.b/c"]`, []pathElement{{key: "metadata"}, {key: "annotations"}, {key: "a.b/c"}}},
+		{`["x[]"].y`, []pathElement{{key: "x[]"}, {key: "y"}}},
+	} {
+		t.Run(x.path, func(t *testing.T) {
+			got, err := parsePath(x.path)
+			require.NoError(t, err)
+			assert.Equal(t, x.want, got)
+		})
+	}
+	for _, path := range []string{"", ".", "a..b", "a[]", "a[0]", `a["b`} {
+		t.Run(path, func(t *testing.T) {
+			_, err := parsePath(path)
+			assert.EqualError(t, err, "invalid field path: "+string(must.Must1(json.Marshal(path))))
+		})
+	}
+}
+
+func TestEngine_Redact(t *testing.T) {
+	d := mock.NewDomainWithClasses("mock", "secret", "pod", "other")
+	plain := mock.Domain("plain")
+	e, err := Build().Domains(d, plain).Config(config.Configs{{
+		Redact: []config.Redaction{
+			{Domain: "mock", Classes: []string{"secret"}, Remove: []string{"data", `metadata.annotations["x.io/last"]`}},
+			{Domain: "mock", Classes: []string{"pod"}, Remove: []string{"spec.containers[].env[].value"}},
+			{Domain: "mock", Mask: []string{`Bearer \S+`}},
+		},
+	}}).Engine()
+	require.NoError(t, err)
+
+	redact := func(class string, o korrel8r.Object) any {
+		t.Helper()
+		v, err := e.Redact(d.Class(class), o)
+		require.NoError(t, err)
+		return v
+	}
+	secret := map[string]any{
+		"metadata": map[string]any{"name": "x", "annotations": map[string]any{"x.io/last": "{data}", "keep": "me"}},
+		"data":     map[string]any{"password": "aHVzaA=="},
+		"size":     1.5,
+	}
+	assert.Equal(t, map[string]any{
+		"metadata": map[string]any{"name": "x", "annotations": map[string]any{"keep": "me"}},
+		"size":     json.Number("1.5"),
+	}, redact("secret", secret))
+	assert.Contains(t, secret, "data", "original object is not modified")
+
+	pod := map[string]any{"spec": map[string]any{"containers": []any{
+		map[string]any{"name": "a", "env": []any{map[string]any{"name": "TOKEN", "value": "s3cr3t"}}},
+		map[string]any{"name": "b", "args": []any{"--auth=Bearer abc123 --verbose"}},
+	}}}
+	assert.Equal(t, map[string]any{"spec": map[string]any{"containers": []any{
+		map[string]any{"name": "a", "env": []any{map[string]any{"name": "TOKEN"}}},
+		map[string]any{"name": "b", "args": []any{"--auth=" + Redacted + " --verbose"}},
+	}}}, redact("pod", pod))
+
+	assert.Equal(t, "log "+Redacted, redact("other", "log Bearer xyz"))
+
+	o := map[string]any{"data": "Bearer xyz"}
+	v, err := e.Redact(plain.Class("x"), o)
+	require.NoError(t, err)
+	assert.Equal(t, o, v, "no redactions")
+}
+
+// redactingDomain has built-in redactions.
+type redactingDomain struct{ *mock.DomainWithClasses }
+
+func (d redactingDomain) Redactions() []config.Redaction {
+	return []config.Redaction{{Domain: d.Name(), Classes: []string{"secret"}, Remove: []string{"data"}}}
+}
+
+func TestEngine_RedactDefaults(t *testing.T) {
+	d := redactingDomain{mock.NewDomainWithClasses("mock", "secret")}
+	secret := map[string]any{"data": "x", "other": "y", "keep": "z"}
+	for _, x := range []struct {
+		name   string
+		redact []config.Redaction
+		want   any
+	}{
+		{name: "default", want: map[string]any{"other": "y", "keep": "z"}},
+		{
+			name:   "extend",
+			redact: []config.Redaction{{Domain: "mock", Classes: []string{"secret"}, Remove: []string{"other"}}},
+			want:   map[string]any{"keep": "z"},
+		},
+		{
+			name:   "replace",
+			redact: []config.Redaction{{Domain: "mock", Classes: []string{"secret"}, Remove: []string{"other"}, Replace: true}},
+			want:   map[string]any{"data": "x", "keep": "z"},
+		},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			configs := config.Configs{{Redact: x.redact}}
+			e, err := Build().Domains(d).Config(configs).Engine()
+			require.NoError(t, err)
+			v, err := e.Redact(d.Class("secret"), secret)
+			require.NoError(t, err)
+			assert.Equal(t, x.want, v)
+			ec := e.EffectiveConfig(configs)
+			require.Len(t, ec.Redact, 1+len(x.redact))
+			assert.Equal(t, EffectiveRedaction{Redaction: d.Redactions()[0], Origin: Origin{Source: BuiltIn}}, ec.Redact[0])
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+)
+
+// RuleStats are cumulative statistics for a rule, collected by followers.
+// Statistics are kept when an engine replaces another, see [Engine.KeepStats].
+//
+// Statistics show which rules never fire and which ones return nothing, so they can be pruned or fixed.
+type RuleStats struct {
+	Applied int           `json:"applied"` // Applied is the number of start objects the rule was applied to.
+	Skipped int           `json:"skipped"` // Skipped is the number of start objects that did not meet rule preconditions.
+	NoQuery int           `json:"noQuery"` // NoQuery is the number of start objects the rule generated no query for, so it did not apply.
+	Errors  int           `json:"errors"`  // Errors is the number of times applying the rule failed.
+	Queries int           `json:"queries"` // Queries is the number of queries generated.
+	Cached  int           `json:"cached"`  // Cached is the number of generated queries already evaluated for another rule, not counted in Results or Objects.
+	Results int           `json:"results"` // Results is the number of generated queries that returned at least one object.
+	Objects int           `json:"objects"` // Objects is the total number of objects returned by generated queries.
+	Latency time.Duration `json:"latency"` // Latency is the total time spent evaluating generated queries.
+}
+
+// stats is a concurrent-safe collection of RuleStats.
+type stats struct {
+	lock  sync.Mutex
+	rules map[string]*RuleStats
+}
+
+func newStats() *stats { return &stats{rules: map[string]*RuleStats{}} }
+
+// add a rule with zero statistics.
+func (s *stats) add(r korrel8r.Rule) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rules[r.Name()] = &RuleStats{}
+}
+
+// update the statistics for a rule.
+func (s *stats) update(r korrel8r.Rule, f func(*RuleStats)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rs := s.rules[r.Name()]
+	if rs == nil { // Rule was not added by the Builder.
+		rs = &RuleStats{}
+		s.rules[r.Name()] = rs
+	}
+	f(rs)
+}
+
+// get a copy of all rule statistics.
+func (s *stats) get() map[string]RuleStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	m := make(map[string]RuleStats, len(s.rules))
+	for name, rs := range s.rules {
+		m[name] = *rs
+	}
+	return m
+}
+
+// keep statistics from old for rules that are also in s.
+func (s *stats) keep(old *stats) {
+	if old == s {
+		return
+	}
+	oldStats := old.get()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name, rs := range s.rules {
+		if o, ok := oldStats[name]; ok {
+			*rs = o
+		}
+	}
+}
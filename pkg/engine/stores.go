@@ -11,11 +11,14 @@ import (
 	"reflect"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
 	"github.com/korrel8r/korrel8r/pkg/config"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/ptr"
 )
 
 var (
@@ -35,22 +38,42 @@ type store struct {
 
 	domain korrel8r.Domain
 	expand func(string) (string, error) // Expand template configuration
+	source string                       // Source of the configuration, may be empty.
+	tuning config.StoreTuning           // Effective tuning for this store.
+	sem    chan struct{}                // Limits concurrent Get calls.
 }
 
 func (s *store) Domain() korrel8r.Domain { return s.domain }
 
 // Get (re-)creates the store as required. Concurrent safe.
+// The constraint is completed with defaults from the store tuning.
+// Concurrent calls are limited by the store tuning, default is to serialize Get per store.
 func (s *store) Get(ctx context.Context, q korrel8r.Query, constraint *korrel8r.Constraint, result korrel8r.Appender) (err error) {
-	s.lock.Lock() // Lock for duration of Get() - serialize Get per store.
-	defer s.lock.Unlock()
-	if _, err := s.ensure(); err != nil {
+	if s.sem != nil {
+		select {
+		case s.sem <- struct{}{}:
+			defer func() { <-s.sem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	ks, err := s.Ensure()
+	if err != nil {
 		return err
 	}
-	err = s.Store.Get(ctx, q, constraint, result)
+	constraint = s.constraint(constraint)
+	if timeout := constraint.GetTimeout(); timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err = ks.Get(ctx, q, constraint, result)
 	if err != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
 		s.Err = err
 		s.ErrCount++
-		if s.Original != nil { // Only re-create if there is some configuration.
+		if s.Original != nil && s.Store == ks { // Only re-create if there is some configuration.
 			// Close the broken store if it is an io.Closer()
 			if c, ok := s.Store.(io.Closer); ok {
 				_ = c.Close()
@@ -61,6 +84,38 @@ func (s *store) Get(ctx context.Context, q korrel8r.Query, constraint *korrel8r.
 	return err
 }
 
+// Close the store if it was created from configuration and is an [io.Closer].
+func (s *store) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c, ok := s.Store.(io.Closer); ok && s.Original != nil {
+		_ = c.Close()
+		s.Store = nil
+	}
+}
+
+// constraint returns a copy of c with unset values filled in from the store tuning,
+// then from global defaults.
+func (s *store) constraint(c *korrel8r.Constraint) *korrel8r.Constraint {
+	c2 := korrel8r.Constraint{}
+	if c != nil {
+		c2 = *c
+	}
+	if c2.Limit == nil && s.tuning.Limit > 0 {
+		c2.Limit = ptr.To(s.tuning.Limit)
+	}
+	if c2.Timeout == nil && s.tuning.Timeout.Duration > 0 {
+		c2.Timeout = ptr.To(s.tuning.Timeout.Duration)
+	}
+	if c2.Start == nil && s.tuning.Lookback.Duration > 0 {
+		if c2.End == nil {
+			c2.End = ptr.To(time.Now())
+		}
+		c2.Start = ptr.To(c2.End.Add(-s.tuning.Lookback.Duration))
+	}
+	return c2.Default()
+}
+
 // Ensure the store is connected.
 func (s *store) Ensure() (korrel8r.Store, error) {
 	s.lock.Lock()
@@ -90,6 +145,9 @@ func (s *store) ensure() (korrel8r.Store, error) {
 		}
 		s.Expanded[k] = v
 	}
+	if _, ok := s.Expanded[config.StoreKeyMaxResponseSize]; !ok && s.tuning.MaxResponseSize > 0 {
+		s.Expanded[config.StoreKeyMaxResponseSize] = strconv.FormatInt(s.tuning.MaxResponseSize, 10) // From domain tuning.
+	}
 	// Create the store
 	if _, ok := s.Expanded[config.StoreKeyMock]; ok {
 		// Special case for mock store, any domain can have a mock store.
@@ -108,13 +166,16 @@ type stores struct {
 	domain korrel8r.Domain
 	stores []*store
 	expand func(string) (string, error)
+	secret func(string) bool  // True if a template uses secrets.
+	tuning config.StoreTuning // Tuning for all stores in the domain.
 }
 
 func newStores(e *Engine, d korrel8r.Domain) *stores {
 	return &stores{
 		domain: d,
 		stores: []*store{},
-		expand: func(s string) (string, error) { return e.execTemplate(s, nil) },
+		expand: e.expandStore,
+		secret: e.usesSecret,
 	}
 }
 
@@ -131,12 +192,28 @@ func (ss *stores) Add(newStore *store) error {
 	return nil
 }
 
+// tune sets the effective tuning for each store from its configuration and the domain tuning.
+func (ss *stores) tune() error {
+	for _, s := range ss.stores {
+		t, err := s.Original.Tuning()
+		if err != nil {
+			return fmt.Errorf("%v store: %w", ss.domain, err)
+		}
+		s.tuning = t.Merge(ss.tuning)
+		s.sem = make(chan struct{}, max(s.tuning.MaxConcurrent, 1))
+	}
+	return nil
+}
+
 func (ss *stores) Get(ctx context.Context, q korrel8r.Query, constraint *korrel8r.Constraint, result korrel8r.Appender) error {
 	var (
 		errs error
 		ok   bool
 	)
 	for _, s := range ss.stores {
+		if !constraint.MatchCluster(s.Original[config.StoreKeyCluster]) {
+			continue
+		}
 		// Iterate over stores and accumulate all results.
 		err := s.Get(ctx, q, constraint, result)
 		ok = (err == nil) || ok       // Remember if any call succeeds.
@@ -148,21 +225,45 @@ func (ss *stores) Get(ctx context.Context, q korrel8r.Query, constraint *korrel8
 	return errs
 }
 
+// Close all stores, see [store.Close].
+func (ss *stores) Close() {
+	for _, s := range ss.stores {
+		s.Close()
+	}
+}
+
 // Configs returns the expanded configurations for each store.
+// Credentials (see [secretKey]) and values computed from secrets are replaced by [Redacted].
 func (ss *stores) Configs() (ret []config.Store) {
 	for _, s := range ss.stores {
-		sc := maps.Clone(s.Expanded)
-		if s.Err != nil {
-			sc[config.StoreKeyError] = s.Err.Error()
-		}
-		if s.ErrCount > 0 {
-			sc[config.StoreKeyErrorCount] = strconv.Itoa(s.ErrCount)
-		}
-		ret = append(ret, sc)
+		ret = append(ret, ss.config(s))
 	}
 	return ret
 }
 
+// config returns the expanded configuration and status of a store, with secrets redacted.
+func (ss *stores) config(s *store) config.Store {
+	sc := maps.Clone(s.Expanded)
+	for k, v := range s.Original {
+		if _, ok := sc[k]; ok && (secretKey(k) || ss.secret(v)) {
+			sc[k] = Redacted
+		}
+	}
+	if s.Err != nil {
+		sc[config.StoreKeyError] = s.Err.Error()
+	}
+	if s.ErrCount > 0 {
+		sc[config.StoreKeyErrorCount] = strconv.Itoa(s.ErrCount)
+	}
+	return sc
+}
+
+// secretKey returns true for store keys with credential values: passwords, tokens and request headers.
+// Headers are included because they often carry credentials, for example `header.Authorization`.
+func secretKey(k string) bool {
+	return k == config.StoreKeyPassword || k == config.StoreKeyToken || strings.HasPrefix(k, config.StoreKeyHeaderPrefix)
+}
+
 // Ensure calls [configuredStore.Ensure] on all configured stores.
 func (ss *stores) Ensure() (ks []korrel8r.Store) {
 	for _, s := range ss.stores {
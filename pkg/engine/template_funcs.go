@@ -0,0 +1,75 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/pkg/rules"
+)
+
+// ServiceAccountTokenFile is the location of the service account token mounted in a Kubernetes pod.
+const ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Redacted replaces store configuration values that are computed from secrets.
+const Redacted = "<redacted>"
+
+// storeFuncs are template functions for loading values into store configurations.
+// They are only available to store templates, not to rule templates,
+// so rules cannot copy files or environment variables into queries.
+//
+//	readFile PATH
+//	    Contents of a file, with leading and trailing white space removed. The value is redacted.
+//	secret PATH
+//	    Same as readFile, for a mounted secret file.
+//	secretEnv NAME
+//	    Value of an environment variable, error if it is not set. The value is redacted.
+//	serviceAccountToken
+//	    The pod service account token. The value is redacted.
+//	env NAME, expandenv TEXT
+//	    The sprig functions for non-secret environment variables, they are removed from rule templates.
+var storeFuncs = template.FuncMap{
+	"env":                 os.Getenv,
+	"expandenv":           os.ExpandEnv,
+	"readFile":            readFile,
+	"secret":              readFile,
+	"secretEnv":           secretEnv,
+	"serviceAccountToken": func() (string, error) { return readFile(ServiceAccountTokenFile) },
+}
+
+// secretFuncs are the names of storeFuncs that return secret values.
+// Files may contain credentials, so readFile values are secret too.
+var secretFuncs = []string{"readFile", "secret", "secretEnv", "serviceAccountToken"}
+
+// newStoreTemplate parses a store configuration template, with storeFuncs as well as the rule template funcs.
+func (e *Engine) newStoreTemplate(text string) (*template.Template, error) {
+	return e.NewTemplate(text).Funcs(storeFuncs).Parse(text)
+}
+
+func readFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func secretEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable not set: %v", name)
+	}
+	return v, nil
+}
+
+// usesSecret returns true if a template calls any of the secretFuncs.
+func (e *Engine) usesSecret(text string) bool {
+	tmpl, err := e.newStoreTemplate(text)
+	if err != nil {
+		return false // Not a valid template, can't call anything.
+	}
+	return rules.Calls(tmpl, secretFuncs...)
+}
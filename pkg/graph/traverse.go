@@ -3,6 +3,9 @@
 package graph
 
 import (
+	"cmp"
+	"slices"
+
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 	"github.com/korrel8r/korrel8r/pkg/unique"
 	"golang.org/x/exp/maps"
@@ -64,10 +67,35 @@ func (g *Graph) Neighbours(start korrel8r.Class, depth int, f func(*Line) bool)
 
 // traverseEdge calls f(l) for each line l in the edge.
 // Returns true if any call to f(l) returns true.
+//
+// Lines are visited in order of decreasing rule priority, see [korrel8r.PriorityRule].
+// If f(l) returns true for an exclusive rule, lines with lower priority are skipped.
 func (g *Graph) traverseEdge(edge graph.Edge, f func(l *Line) bool) (ok bool) {
-	lines := g.Lines(edge.From().ID(), edge.To().ID())
-	for lines.Next() {
-		ok = f(lines.Line().(*Line)) || ok
+	var lines []*Line
+	it := g.Lines(edge.From().ID(), edge.To().ID())
+	for it.Next() {
+		lines = append(lines, it.Line().(*Line))
+	}
+	slices.SortStableFunc(lines, func(a, b *Line) int {
+		pa, _ := korrel8r.GetRulePriority(a.Rule)
+		pb, _ := korrel8r.GetRulePriority(b.Rule)
+		if n := cmp.Compare(pb, pa); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.ID(), b.ID())
+	})
+	suppressed, below := false, 0
+	for _, l := range lines {
+		priority, exclusive := korrel8r.GetRulePriority(l.Rule)
+		if suppressed && priority < below {
+			continue // Suppressed by a higher priority exclusive rule.
+		}
+		if f(l) {
+			ok = true
+			if exclusive && !suppressed {
+				suppressed, below = true, priority
+			}
+		}
 	}
 	return ok
 }
@@ -14,6 +14,14 @@ type Constraint struct {
 	Timeout *time.Duration `json:"timeout,omitempty" swaggertype:"string"`                  // Timeout per request, h/m/s/ms/ns format
 	Start   *time.Time     `json:"start,omitempty" swaggertype:"string" format:"date-time"` // Start of time interval, quoted RFC 3339 format.
 	End     *time.Time     `json:"end,omitempty" swaggertype:"string" format:"date-time"`   // End of time interval, quoted RFC 3339 format.
+	Cluster string         `json:"cluster,omitempty"`                                       // Cluster name, use only stores for this cluster.
+}
+
+// MatchCluster returns true if a store for cluster should be used with this constraint.
+// If the constraint names a cluster, only stores with the same cluster name are used,
+// stores with no cluster name are not. Safe to call with c == nil.
+func (c *Constraint) MatchCluster(cluster string) bool {
+	return c == nil || c.Cluster == "" || c.Cluster == cluster
 }
 
 // CompareTime returns -1 if t is before the constraint interval, +1 if it is after,
@@ -96,10 +104,12 @@ func (c *Constraint) MarshalLog() any {
 		Limit      *int
 		Timeout    *time.Duration
 		Start, End string
+		Cluster    string `json:",omitempty"`
 	}{
 		Limit:   c.Limit,
 		Timeout: c.Timeout,
 		Start:   c.Start.Format(time.RFC3339Nano),
 		End:     c.End.Format(time.RFC3339Nano),
+		Cluster: c.Cluster,
 	}
 }
@@ -105,6 +105,16 @@ type Previewer interface {
 	Preview(Object) string
 }
 
+// QueryBuilder is optionally implemented by Domain implementations that can build a query from named parameters.
+// It is used by declarative mapping rules, so that query syntax and escaping is done correctly by the domain.
+//
+// Parameter names depend on the domain, for example label names for a label selector query.
+// An error is returned for unknown or invalid parameters.
+type QueryBuilder interface {
+	// BuildQuery builds a query for class with the given parameter values.
+	BuildQuery(class Class, params map[string]string) (Query, error)
+}
+
 // Appender gathers results from Store.Get calls.
 //
 // Not required for a domain implementations: implemented by [Result]
@@ -127,5 +137,91 @@ type Rule interface {
 	Name() string
 }
 
+// MultiRule is optionally implemented by Rule implementations that can generate several queries from one start object.
+// The queries may be for different goal classes, all in the same goal domain.
+//
+// Use [ApplyRule] to apply a rule that may or may not be a MultiRule.
+type MultiRule interface {
+	Rule
+	// ApplyAll applies the rule to a start Object, returns a list of queries for results.
+	ApplyAll(start Object) ([]Query, error)
+}
+
+// ApplyRule applies a rule to a start object and returns all the generated queries.
+// Calls [MultiRule.ApplyAll] if rule is a MultiRule, [Rule.Apply] otherwise.
+func ApplyRule(rule Rule, start Object) ([]Query, error) {
+	if mr, ok := rule.(MultiRule); ok {
+		return mr.ApplyAll(start)
+	}
+	q, err := rule.Apply(start)
+	if q == nil {
+		return nil, err
+	}
+	return []Query{q}, err
+}
+
+// ContextRule is optionally implemented by Rule implementations that can make requests while they are applied,
+// for example rule templates that look up related objects.
+// The context limits the time for requests, and carries the identity of the user that the rule is applied for.
+//
+// Use [ApplyRuleContext] to apply a rule that may or may not be a ContextRule.
+type ContextRule interface {
+	Rule
+	// ApplyContext applies the rule to a start Object, returns a list of queries for results.
+	ApplyContext(ctx context.Context, start Object) ([]Query, error)
+}
+
+// ApplyRuleContext calls [ContextRule.ApplyContext] if rule is a ContextRule, [ApplyRule] otherwise.
+func ApplyRuleContext(ctx context.Context, rule Rule, start Object) ([]Query, error) {
+	if cr, ok := rule.(ContextRule); ok {
+		return cr.ApplyContext(ctx, start)
+	}
+	return ApplyRule(rule, start)
+}
+
+// ConditionalRule is optionally implemented by Rule implementations that have preconditions.
+// Preconditions are cheap to test, and can be tested before applying the rule.
+//
+// Use [RuleApplies] to test a rule that may or may not be a ConditionalRule.
+type ConditionalRule interface {
+	Rule
+	// Applies returns nil if the rule can apply to a start Object of class,
+	// or an error giving the reason it does not.
+	Applies(class Class, start Object) error
+}
+
+// RuleApplies returns nil if rule can apply to start object of class, or an error explaining why not.
+// Rules that are not a [ConditionalRule] apply to all objects of their start classes.
+func RuleApplies(rule Rule, class Class, start Object) error {
+	if cr, ok := rule.(ConditionalRule); ok {
+		return cr.Applies(class, start)
+	}
+	return nil
+}
+
+// PriorityRule is optionally implemented by Rule implementations that have a priority.
+//
+// When several rules connect the same start and goal classes, higher priority rules are followed first.
+// If an exclusive rule produces results, lower priority rules for the same start and goal are skipped.
+// This allows fallback rules that are only used when the data for a primary rule is missing.
+//
+// Use [GetRulePriority] to get the priority of a rule that may or may not be a PriorityRule.
+type PriorityRule interface {
+	Rule
+	// Priority of the rule, higher values are followed first. Default is 0.
+	Priority() int
+	// Exclusive is true if results from this rule suppress lower priority rules.
+	Exclusive() bool
+}
+
+// GetRulePriority returns the priority and exclusive flag of a rule.
+// Rules that are not a [PriorityRule] have priority 0 and are not exclusive.
+func GetRulePriority(rule Rule) (priority int, exclusive bool) {
+	if pr, ok := rule.(PriorityRule); ok {
+		return pr.Priority(), pr.Exclusive()
+	}
+	return 0, false
+}
+
 // NameSeparator used in DOMAIN:CLASS and DOMAIN:CLASS:QUERY strings.
 const NameSeparator = ":"
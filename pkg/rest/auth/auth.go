@@ -22,13 +22,19 @@ func Wrap(next http.RoundTripper) http.RoundTripper {
 type roundTripper struct{ next http.RoundTripper }
 
 func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	ctx := req.Context()
-	if auth, ok := ctx.Value(authKey{}).(string); ok {
+	if auth, ok := FromContext(req.Context()); ok {
 		req.Header.Set(authorization, auth)
 	}
 	return rt.next.RoundTrip(req)
 }
 
+// FromContext returns the authorization header of the incoming request.
+// Returns ok == false if ctx is not an authorization-forwarding context.
+func FromContext(ctx context.Context) (auth string, ok bool) {
+	auth, ok = ctx.Value(authKey{}).(string)
+	return auth, ok
+}
+
 type authKey struct{}
 
 const authorization = "Authorization"
@@ -3,6 +3,7 @@
 package auth_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
@@ -40,3 +41,11 @@ func Test_Context_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestFromContext(t *testing.T) {
+	_, ok := auth.FromContext(context.Background())
+	assert.False(t, ok)
+	got, ok := auth.FromContext(auth.Context(&http.Request{Header: http.Header{"Authorization": []string{"Bearer x"}}}))
+	assert.True(t, ok)
+	assert.Equal(t, "Bearer x", got)
+}
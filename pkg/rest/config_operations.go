@@ -32,3 +32,14 @@ func (a *API) PutConfig(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, config)
 }
+
+// GetConfig handler
+//
+//	@router		/config [get]
+//	@summary		Get the effective configuration, with the source of each rule and store.
+//	@description	Store passwords, tokens, headers and values computed from secrets are redacted.
+//	@success	200		{object}	EffectiveConfig
+//	@failure	default	{object}	any
+func (a *API) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, a.Engine.EffectiveConfig(a.Configs))
+}
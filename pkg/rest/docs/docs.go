@@ -29,6 +29,24 @@ const docTemplate = `{
     "basePath": "{{.BasePath}}",
     "paths": {
         "/config": {
+            "get": {
+                "description": "Store passwords, tokens, headers and values computed from secrets are redacted.",
+                "summary": "Get the effective configuration, with the source of each rule and store.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/EffectiveConfig"
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            },
             "put": {
                 "summary": "Change key configuration settings at runtime.",
                 "parameters": [
@@ -249,6 +267,60 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/rules/apply": {
+            "post": {
+                "description": "If the rule does not apply or fails, the response is OK with an ` + "`" + `error` + "`" + ` field giving the reason.",
+                "summary": "Apply a single rule to a start object, return the generated queries.",
+                "parameters": [
+                    {
+                        "description": "rule and start object",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/RuleApply"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/RuleApplyResult"
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        },
+        "/rules/stats": {
+            "get": {
+                "description": "Statistics are kept when the configuration is reloaded, for rules with the same name.",
+                "summary": "Get cumulative statistics for each rule since the server started.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/RuleStats"
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
@@ -263,6 +335,10 @@ const docTemplate = `{
             "description": "Constraint constrains the objects that will be included in search results.",
             "type": "object",
             "properties": {
+                "cluster": {
+                    "description": "Cluster name, use only stores for this cluster.",
+                    "type": "string"
+                },
                 "end": {
                     "description": "End of time interval, quoted RFC 3339 format.",
                     "type": "string",
@@ -323,6 +399,33 @@ const docTemplate = `{
                 }
             }
         },
+        "EffectiveConfig": {
+            "description": "EffectiveConfig is the configuration in use, with the source file and line of each rule and store.",
+            "type": "object",
+            "properties": {
+                "redact": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/engine.EffectiveRedaction"
+                    }
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/engine.EffectiveRule"
+                    }
+                },
+                "stores": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/engine.EffectiveStore"
+                    }
+                },
+                "tuning": {
+                    "$ref": "#/definitions/engine.EffectiveTuning"
+                }
+            }
+        },
         "Goals": {
             "description": "Starting point for a goals search.",
             "type": "object",
@@ -426,6 +529,94 @@ const docTemplate = `{
                 }
             }
         },
+        "RuleApply": {
+            "description": "RuleApply applies a single rule to a start object, for testing rules.",
+            "type": "object",
+            "properties": {
+                "class": {
+                    "description": "Class of ` + "`" + `object` + "`" + `, required if the rule has more than one start class.",
+                    "type": "string"
+                },
+                "constraint": {
+                    "$ref": "#/definitions/Constraint"
+                },
+                "execute": {
+                    "description": "Execute generated queries and return result counts.",
+                    "type": "boolean"
+                },
+                "object": {
+                    "description": "Start object of ` + "`" + `class` + "`" + ` serialized as JSON.",
+                    "type": "object"
+                },
+                "rule": {
+                    "description": "Name of the rule to apply.",
+                    "type": "string"
+                }
+            }
+        },
+        "RuleApplyResult": {
+            "description": "RuleApplyResult is the result of applying a rule to a start object.",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error if the rule did not generate queries.",
+                    "type": "string"
+                },
+                "queries": {
+                    "description": "Queries generated by the rule, with counts if executed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/QueryCount"
+                    }
+                }
+            }
+        },
+        "RuleStats": {
+            "description": "RuleStats are cumulative statistics for a rule since the server started. Statistics are kept when the configuration is reloaded, for rules with the same name.",
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Number of start objects the rule was applied to.",
+                    "type": "integer"
+                },
+                "cached": {
+                    "description": "Number of generated queries already evaluated for another rule, not counted in results.",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Number of times applying the rule failed.",
+                    "type": "integer"
+                },
+                "latency": {
+                    "description": "Total time spent evaluating generated queries, as a duration string.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name of the rule.",
+                    "type": "string"
+                },
+                "noQuery": {
+                    "description": "Number of start objects the rule generated no query for, so it did not apply.",
+                    "type": "integer"
+                },
+                "objects": {
+                    "description": "Total number of objects returned by generated queries.",
+                    "type": "integer"
+                },
+                "queries": {
+                    "description": "Number of queries generated.",
+                    "type": "integer"
+                },
+                "results": {
+                    "description": "Number of generated queries that returned at least one object.",
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "Number of start objects that did not meet rule preconditions.",
+                    "type": "integer"
+                }
+            }
+        },
         "Start": {
             "description": "Start identifies a set of starting objects for correlation.",
             "type": "object",
@@ -456,6 +647,283 @@ const docTemplate = `{
             "additionalProperties": {
                 "type": "string"
             }
+        },
+        "config.ClassSpec": {
+            "type": "object",
+            "properties": {
+                "classes": {
+                    "description": "Classes is a list of class names to be selected from the domain.\nIf absent, all classes in the domain are selected.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "domain": {
+                    "description": "Domain is the domain for selected classes.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.Equivalence": {
+            "type": "object",
+            "properties": {
+                "goal": {
+                    "$ref": "#/definitions/config.FieldSpec"
+                },
+                "start": {
+                    "$ref": "#/definitions/config.FieldSpec"
+                }
+            }
+        },
+        "config.FieldSpec": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field path in objects of the class, for example ` + "`" + `.Namespace` + "`" + ` or ` + "`" + `.Attributes[\"k8s.namespace.name\"]` + "`" + `\nA path ending in ` + "`" + `?` + "`" + ` is optional in the rule that starts from this class, see [OptionalSuffix].",
+                    "type": "string"
+                },
+                "param": {
+                    "description": "Param is the query parameter for the field, for example ` + "`" + `namespace` + "`" + ` or ` + "`" + `resource.k8s.namespace.name` + "`" + `.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.PluginSpec": {
+            "type": "object",
+            "properties": {
+                "memoryLimit": {
+                    "description": "MemoryLimit is the maximum memory for the module in megabytes, default 16.",
+                    "type": "integer"
+                },
+                "module": {
+                    "description": "Module is the path to a WebAssembly module file.\nA relative path is relative to the location of the configuration file containing it.",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Timeout is the maximum time for the module to process one start object, default 1s.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.ResultSpec": {
+            "type": "object",
+            "properties": {
+                "mapping": {
+                    "description": "Mapping maps goal query parameters to field paths in the start object, instead of the Query template.\nThe goal domain builds the query, so query syntax and escaping are always correct.\nThe rule must have a single goal class, and does not apply if any field is empty.\n\nField paths are field names and quoted map keys, for example: ` + "`" + `.Labels.namespace` + "`" + `, ` + "`" + `.Attributes[\"k8s.pod.name\"]` + "`" + `.\nA path ending in ` + "`" + `?` + "`" + ` is optional, see [OptionalSuffix]. The rule does not apply if all fields are empty.\nParameter names depend on the goal domain:\n  - k8s: ` + "`" + `namespace` + "`" + `, ` + "`" + `name` + "`" + `, ` + "`" + `labels.KEY` + "`" + `, ` + "`" + `fields.KEY` + "`" + `\n  - log: LogQL stream label names.\n  - trace: TraceQL attribute names, for example ` + "`" + `resource.k8s.pod.name` + "`" + `.\n  - alert: alert label names.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "plugin": {
+                    "description": "Plugin is a WebAssembly module to generate queries, instead of the Query template.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.PluginSpec"
+                        }
+                    ]
+                },
+                "query": {
+                    "description": "Query template generates a query object suitable for the goal store.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.Store": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "config.StoreTuning": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "Limit is the default maximum number of objects returned by a query.",
+                    "type": "integer"
+                },
+                "lookback": {
+                    "description": "Lookback is the default duration of the query time interval, ending at the constraint end time.",
+                    "type": "string"
+                },
+                "maxConcurrent": {
+                    "description": "MaxConcurrent is the maximum number of concurrent queries to a store, default 1.",
+                    "type": "integer"
+                },
+                "maxResponseSize": {
+                    "description": "MaxResponseSize is the maximum size of a response in bytes, for stores that connect over HTTP.",
+                    "type": "integer"
+                },
+                "timeout": {
+                    "description": "Timeout is the default timeout for a query.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.WhenSpec": {
+            "type": "object",
+            "properties": {
+                "classes": {
+                    "description": "Classes restricts the rule to start objects in one of these classes from the start domain.\nAliases are allowed.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "fields": {
+                    "description": "Fields maps template field expressions (e.g. ` + "`" + `.Spec.NodeName` + "`" + `) to regular expressions.\nThe field value must be non-empty and match the regular expression.\nAn empty regular expression matches any non-empty value.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "labels": {
+                    "description": "Labels the start object must have.\nAn empty value means the label must be present with any value.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "engine.EffectiveRedaction": {
+            "type": "object",
+            "properties": {
+                "classes": {
+                    "description": "Classes to redact, aliases are allowed. If absent, all classes in the domain are redacted.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "domain": {
+                    "description": "Domain of the classes to redact.",
+                    "type": "string"
+                },
+                "mask": {
+                    "description": "Mask lists regular expressions, text that matches in any string value is replaced by ` + "`" + `\u003credacted\u003e` + "`" + `.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "origin": {
+                    "$ref": "#/definitions/engine.Origin"
+                },
+                "remove": {
+                    "description": "Remove lists field paths to remove, using the JSON field names of objects.\nPath elements are separated by '.', ` + "`" + `[]` + "`" + ` selects every element of a list,\nand ` + "`" + `[\"KEY\"]` + "`" + ` selects a map key that contains '.' or '['.\nFor example: ` + "`" + `data` + "`" + `, ` + "`" + `spec.containers[].env[].value` + "`" + `, ` + "`" + `metadata.annotations[\"example.com/secret\"]` + "`" + `.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "replace": {
+                    "description": "Replace earlier redactions for the same domain or classes, including built-in defaults,\ninstead of adding to them.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "engine.EffectiveRule": {
+            "type": "object",
+            "properties": {
+                "bidirectional": {
+                    "description": "Bidirectional declares fields of the start and goal classes that have equivalent values,\ninstead of a Result. Two mapping rules are generated, one in each direction,\nnamed NAME/forward (start to goal) and NAME/inverse (goal to start).\nStart and goal must each be a single class. See [ResultSpec.Mapping] for fields and parameters.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/config.Equivalence"
+                    }
+                },
+                "exclusive": {
+                    "description": "Exclusive rules suppress lower priority rules with the same start and goal classes.\nIf an exclusive rule produces results, lower priority rules are not followed.\nThis is useful for fallback rules that are only needed when primary data is missing.",
+                    "type": "boolean"
+                },
+                "goal": {
+                    "description": "Goal specifies the set of classes that this rule can produce.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.ClassSpec"
+                        }
+                    ]
+                },
+                "name": {
+                    "description": "Name is a short, descriptive name.\nIf omitted, a name is generated from Start and Goal.",
+                    "type": "string"
+                },
+                "origin": {
+                    "$ref": "#/definitions/engine.Origin"
+                },
+                "priority": {
+                    "description": "Priority orders rules with the same start and goal classes, higher priority rules are followed first.\nDefault priority is 0.",
+                    "type": "integer"
+                },
+                "result": {
+                    "description": "TemplateResult contains templates to generate the result of applying this rule.\nEach template is applied to an object from one of the ` + "`" + `start` + "`" + ` classes.\nIf any template yields a blank string or an error, the rule does not apply.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.ResultSpec"
+                        }
+                    ]
+                },
+                "start": {
+                    "description": "Start specifies the set of classes that this rule can apply to.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.ClassSpec"
+                        }
+                    ]
+                },
+                "when": {
+                    "description": "When contains optional preconditions that a start object must satisfy for the rule to apply.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.WhenSpec"
+                        }
+                    ]
+                }
+            }
+        },
+        "engine.EffectiveStore": {
+            "type": "object",
+            "properties": {
+                "origin": {
+                    "$ref": "#/definitions/engine.Origin"
+                },
+                "store": {
+                    "$ref": "#/definitions/config.Store"
+                }
+            }
+        },
+        "engine.EffectiveTuning": {
+            "type": "object",
+            "properties": {
+                "domains": {
+                    "description": "Domains maps domain names to tuning for all stores of the domain.\nStore keys with the same names override the domain tuning for a single store.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/config.StoreTuning"
+                    }
+                },
+                "origin": {
+                    "$ref": "#/definitions/engine.Origin"
+                },
+                "requestTimeout": {
+                    "description": "RequestTimeout cancel requests if they last longer than this timeout.\nCancelling a correlation operation may return an error or a partial result (HTTP 206).",
+                    "type": "string"
+                }
+            }
+        },
+        "engine.Origin": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "description": "Line number in the source, 0 if not known.",
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source file or URL, see [config.Config.Source], or [BuiltIn] for defaults provided by a domain.",
+                    "type": "string"
+                }
+            }
         }
     }
 }`
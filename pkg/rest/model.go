@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 
 	"github.com/korrel8r/korrel8r/pkg/config"
+	"github.com/korrel8r/korrel8r/pkg/engine"
 	"github.com/korrel8r/korrel8r/pkg/korrel8r"
 )
 
@@ -108,3 +109,36 @@ type Graph struct {
 	Nodes []Node `json:"nodes,omitempty"`
 	Edges []Edge `json:"edges,omitempty"`
 } // @name Graph
+
+// @description RuleApply applies a single rule to a start object, for testing rules.
+type RuleApply struct {
+	Rule       string          `json:"rule"`                        // Name of the rule to apply.
+	Class      string          `json:"class,omitempty"`             // Class of `object`, required if the rule has more than one start class.
+	Object     json.RawMessage `json:"object" swaggertype:"object"` // Start object of `class` serialized as JSON.
+	Execute    bool            `json:"execute,omitempty"`           // Execute generated queries and return result counts.
+	Constraint *Constraint     `json:"constraint,omitempty"`
+} // @name RuleApply
+
+// @description RuleApplyResult is the result of applying a rule to a start object.
+type RuleApplyResult struct {
+	Queries []QueryCount `json:"queries,omitempty"` // Queries generated by the rule, with counts if executed.
+	Error   string       `json:"error,omitempty"`   // Error if the rule did not generate queries.
+} // @name RuleApplyResult
+
+// @description RuleStats are cumulative statistics for a rule since the server started.
+// @description Statistics are kept when the configuration is reloaded, for rules with the same name.
+type RuleStats struct {
+	Name    string `json:"name"`    // Name of the rule.
+	Applied int    `json:"applied"` // Number of start objects the rule was applied to.
+	Skipped int    `json:"skipped"` // Number of start objects that did not meet rule preconditions.
+	NoQuery int    `json:"noQuery"` // Number of start objects the rule generated no query for, so it did not apply.
+	Errors  int    `json:"errors"`  // Number of times applying the rule failed.
+	Queries int    `json:"queries"` // Number of queries generated.
+	Cached  int    `json:"cached"`  // Number of generated queries already evaluated for another rule, not counted in results.
+	Results int    `json:"results"` // Number of generated queries that returned at least one object.
+	Objects int    `json:"objects"` // Total number of objects returned by generated queries.
+	Latency string `json:"latency"` // Total time spent evaluating generated queries, as a duration string.
+} // @name RuleStats
+
+// @description EffectiveConfig is the configuration in use, with the source file and line of each rule and store.
+type EffectiveConfig = engine.EffectiveConfig // @name EffectiveConfig
@@ -25,6 +25,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,6 +51,8 @@ var BasePath = docs.SwaggerInfo.BasePath
 type API struct {
 	Engine  *engine.Engine
 	Configs config.Configs
+
+	lock sync.RWMutex // Held for reading while handling a request, for writing by Update.
 }
 
 // New API instance, registers  handlers with a gin Engine.
@@ -66,12 +70,34 @@ func New(e *engine.Engine, c config.Configs, r *gin.Engine) (*API, error) {
 	v.POST("/graphs/goals", a.GraphsGoals)
 	v.POST("/graphs/neighbours", a.GraphsNeighbours)
 	v.POST("/lists/goals", a.ListsGoals)
+	v.GET("/config", a.GetConfig)
 	v.PUT("/config", a.PutConfig)
+	v.GET("/rules/stats", a.RulesStats)
+	v.POST("/rules/apply", a.RulesApply)
 	return a, nil
 }
 
 // Close cleans any persistent resources.
-func (a *API) Close() {}
+func (a *API) Close() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.Engine != nil {
+		a.Engine.Close()
+	}
+}
+
+// Update replaces the engine and configuration.
+// Waits for requests in progress to complete, new requests use the new engine.
+// The old engine is closed.
+func (a *API) Update(e *engine.Engine, c config.Configs) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.Engine != nil && a.Engine != e {
+		e.KeepStats(a.Engine)
+		a.Engine.Close()
+	}
+	a.Engine, a.Configs = e, c
+}
 
 func (a *API) handleSwagger(c *gin.Context) {
 	// Set the SwaggerInfo Host to be consistent with the incoming request URL so the test UI will work.
@@ -215,13 +241,107 @@ func (a *API) GetObjects(c *gin.Context) {
 		return
 	}
 	log.V(2).Info("response OK", "objects", len(result.List()))
-	body := []any(result.List())
-	if body == nil {
-		body = []any{} // Return [] on empty, not null.
+	body := []any{} // Return [] on empty, not null.
+	for _, o := range result.List() {
+		v, err := a.Engine.Redact(query.Class(), o)
+		if !check(c, http.StatusInternalServerError, err) {
+			return
+		}
+		body = append(body, v)
 	}
 	c.JSON(http.StatusOK, body)
 }
 
+// RulesStats handler
+//
+//	@router		/rules/stats [get]
+//	@summary	Get cumulative statistics for each rule since the server started.
+//	@description	Statistics are kept when the configuration is reloaded, for rules with the same name.
+//	@success	200		{array}		RuleStats
+//	@failure	default	{object}	any
+func (a *API) RulesStats(c *gin.Context) {
+	stats := []RuleStats{} // return [] not null for empty
+	for name, rs := range a.Engine.RuleStats() {
+		stats = append(stats, RuleStats{
+			Name:    name,
+			Applied: rs.Applied,
+			Skipped: rs.Skipped,
+			NoQuery: rs.NoQuery,
+			Errors:  rs.Errors,
+			Queries: rs.Queries,
+			Cached:  rs.Cached,
+			Results: rs.Results,
+			Objects: rs.Objects,
+			Latency: rs.Latency.String(),
+		})
+	}
+	slices.SortFunc(stats, func(a, b RuleStats) int { return strings.Compare(a.Name, b.Name) })
+	c.JSON(http.StatusOK, stats)
+}
+
+// RulesApply handler
+//
+//	@router		/rules/apply [post]
+//	@summary	Apply a single rule to a start object, return the generated queries.
+//	@description	If the rule does not apply or fails, the response is OK with an `error` field giving the reason.
+//	@param		request	body		RuleApply	true	"rule and start object"
+//	@success	200		{object}	RuleApplyResult
+//	@failure	default	{object}	any
+func (a *API) RulesApply(c *gin.Context) {
+	r := RuleApply{}
+	if !check(c, http.StatusBadRequest, c.BindJSON(&r)) {
+		return
+	}
+	rule := a.Engine.Rule(r.Rule)
+	if rule == nil {
+		check(c, http.StatusNotFound, fmt.Errorf("rule not found: %v", r.Rule))
+		return
+	}
+	var class korrel8r.Class
+	switch {
+	case r.Class != "":
+		if class = a.class(c, r.Class); class == nil {
+			return
+		}
+		if !slices.Contains(rule.Start(), class) {
+			check(c, http.StatusBadRequest, fmt.Errorf("class %v is not a start class of rule %v", class, rule.Name()))
+			return
+		}
+	case len(rule.Start()) == 1:
+		class = rule.Start()[0]
+	default:
+		check(c, http.StatusBadRequest, fmt.Errorf("class is required, rule %v has more than one start class", rule.Name()))
+		return
+	}
+	objects := a.objects(c, class, []json.RawMessage{r.Object})
+	if c.IsAborted() {
+		return
+	}
+	result := RuleApplyResult{}
+	err := korrel8r.RuleApplies(rule, class, objects[0])
+	var queries []korrel8r.Query
+	if err == nil {
+		queries, err = korrel8r.ApplyRuleContext(c.Request.Context(), rule, objects[0])
+	}
+	if err != nil {
+		result.Error = err.Error()
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	for _, q := range queries {
+		qc := QueryCount{Query: q.String(), Count: -1}
+		if r.Execute {
+			objects := korrel8r.NewResult(q.Class())
+			if !check(c, http.StatusInternalServerError, a.Engine.Get(c.Request.Context(), q, r.Constraint, objects)) {
+				return
+			}
+			qc.Count = len(objects.List())
+		}
+		result.Queries = append(result.Queries, qc)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 func (a *API) goals(c *gin.Context) (g *graph.Graph, goals []korrel8r.Class) {
 	r := Goals{}
 	if !check(c, http.StatusBadRequest, c.BindJSON(&r)) {
@@ -329,6 +449,8 @@ func (a *API) logger(c *gin.Context) {
 
 // context sets up authorization and deadline context for outgoing requests.
 func (a *API) context(c *gin.Context) {
+	a.lock.RLock() // Don't update engine during a request.
+	defer a.lock.RUnlock()
 	ctx := auth.Context(c.Request) // add authentication
 
 	timeout := korrel8r.DefaultTimeout
@@ -39,6 +39,46 @@ func TestAPI_GetDomains(t *testing.T) {
 	})
 }
 
+func TestAPI_Update(t *testing.T) {
+	e, err := engine.Build().Domains(mock.Domains("foo")...).Engine()
+	require.NoError(t, err)
+	a := newTestAPI(t, e)
+	assertDo(t, a, "GET", "/api/v1alpha1/domains", nil, 200, []Domain{{Name: "foo"}})
+	e, err = engine.Build().Domains(mock.Domains("bar")...).Engine()
+	require.NoError(t, err)
+	a.Update(e, nil)
+	assertDo(t, a, "GET", "/api/v1alpha1/domains", nil, 200, []Domain{{Name: "bar"}})
+}
+
+func TestAPI_GetConfig(t *testing.T) {
+	configs := config.Configs{{
+		Source: "test.yaml",
+		Stores: []config.Store{{"domain": "mock", "password": "hush", "token": "t0k3n", "header.Authorization": "Basic abc", "x": "y"}},
+		Rules: []config.Rule{{
+			Name:   "r",
+			Start:  config.ClassSpec{Domain: "mock"},
+			Goal:   config.ClassSpec{Domain: "mock", Classes: []string{"b"}},
+			Result: config.ResultSpec{Query: "mock:b:x"},
+		}},
+	}}
+	e, err := engine.Build().Domains(mock.NewDomainWithClasses("mock", "a", "b")).Config(configs).Engine()
+	require.NoError(t, err)
+	a := newTestAPI(t, e)
+	a.Update(e, configs)
+	want := EffectiveConfig{
+		Rules: []engine.EffectiveRule{{Rule: configs[0].Rules[0], Origin: engine.Origin{Source: "test.yaml"}}},
+		Stores: []engine.EffectiveStore{{
+			Store: config.Store{
+				"domain": "mock", "password": engine.Redacted, "token": engine.Redacted,
+				"header.Authorization": engine.Redacted, "x": "y",
+			},
+			Origin: engine.Origin{Source: "test.yaml"},
+		}},
+	}
+	want.Rules[0].Start.Classes = []string{"a", "b"}
+	assertDo(t, a, "GET", "/api/v1alpha1/config", nil, 200, want)
+}
+
 func TestAPI_GetDomainClasses(t *testing.T) {
 	e, err := engine.Build().Domains(logDomain.Domain, metric.Domain).Engine()
 	require.NoError(t, err)
@@ -173,6 +213,23 @@ func TestAPI_GetObjects(t *testing.T) {
 	assertDo(t, a, "GET", "/api/v1alpha1/objects?query="+url.QueryEscape(q.String()), nil, 200, want)
 }
 
+func TestAPI_GetObjects_redact(t *testing.T) {
+	d := mock.Domain("x")
+	c := d.Class("y")
+	s := mock.NewStore(d)
+	q := mock.NewQuery(c, "test")
+	type object struct {
+		Name string `json:"name"`
+		Data string `json:"data"`
+	}
+	s.Add(mock.QueryMap{q.String(): []any{object{Name: "a", Data: "secret"}}})
+	e, err := engine.Build().Domains(d).Stores(s).
+		Redactions(config.Redaction{Domain: "x", Classes: []string{"y"}, Remove: []string{"data"}}).Engine()
+	require.NoError(t, err)
+	a := newTestAPI(t, e)
+	assertDo(t, a, "GET", "/api/v1alpha1/objects?query="+url.QueryEscape(q.String()), nil, 200, []any{map[string]any{"name": "a"}})
+}
+
 func TestAPI_GetObjects_empty(t *testing.T) {
 	d := mock.Domain("x")
 	c := d.Class("y")
@@ -186,6 +243,19 @@ func TestAPI_GetObjects_empty(t *testing.T) {
 	require.Equal(t, "[]", w.Body.String())
 }
 
+func TestAPI_RulesApply(t *testing.T) {
+	e := testEngine(t)
+	a := newTestAPI(t, e)
+	assertDo(t, a, "POST", "/api/v1alpha1/rules/apply",
+		RuleApply{Rule: "a-b", Object: json.RawMessage(`"x"`)},
+		200, RuleApplyResult{Queries: []QueryCount{{Query: "mock:b:y", Count: -1}}})
+	assertDo(t, a, "POST", "/api/v1alpha1/rules/apply",
+		RuleApply{Rule: "a-b", Class: "mock:a", Object: json.RawMessage(`"x"`), Execute: true},
+		200, RuleApplyResult{Queries: []QueryCount{{Query: "mock:b:y", Count: 1}}})
+	assert.Equal(t, 404, do(t, a, "POST", "/api/v1alpha1/rules/apply", RuleApply{Rule: "nonesuch", Object: json.RawMessage(`"x"`)}).Code)
+	assert.Equal(t, 400, do(t, a, "POST", "/api/v1alpha1/rules/apply", RuleApply{Rule: "a-b", Class: "mock:b", Object: json.RawMessage(`"x"`)}).Code)
+}
+
 func ginEngine() *gin.Engine {
 	if os.Getenv(gin.EnvGinMode) == "" { // Don't override an explicit env setting.
 		gin.SetMode(gin.TestMode)
@@ -0,0 +1,171 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+)
+
+// Condition is a precondition that a start object must satisfy for a rule to apply.
+// Conditions are tested before the rule template is executed.
+//
+// See [github.com/korrel8r/korrel8r/pkg/config.WhenSpec] for details of configuring conditions.
+type Condition interface {
+	// Test returns nil if a start object of the given class satisfies the condition,
+	// or an error giving the reason it does not.
+	// Class may be nil if it is not known, conditions that depend on the class are true.
+	Test(class korrel8r.Class, start korrel8r.Object) error
+	String() string
+}
+
+// ClassCondition is true for start objects belonging to one of the classes.
+func ClassCondition(classes ...korrel8r.Class) Condition { return classCondition(classes) }
+
+type classCondition []korrel8r.Class
+
+func (c classCondition) Test(class korrel8r.Class, _ korrel8r.Object) error {
+	if class == nil || slices.Contains(c, class) {
+		return nil
+	}
+	return fmt.Errorf("class %v is not one of %v", class.Name(), c.names())
+}
+
+func (c classCondition) String() string { return fmt.Sprintf("classes %v", c.names()) }
+
+func (c classCondition) names() []string {
+	names := make([]string, len(c))
+	for i := range c {
+		names[i] = c[i].Name()
+	}
+	return names
+}
+
+// LabelCondition is true for start objects that have all the labels.
+// An empty value matches any value, but the label must be present.
+//
+// The labels of an object are found from one of:
+//   - a GetLabels() method, for example a Kubernetes resource.
+//   - a Labels field, for example an alert.
+//   - the object itself, if it is a map of strings to strings, for example a metric.
+func LabelCondition(labels map[string]string) Condition { return labelCondition(labels) }
+
+type labelCondition map[string]string
+
+func (c labelCondition) Test(_ korrel8r.Class, start korrel8r.Object) error {
+	labels, ok := Labels(start)
+	if !ok {
+		return fmt.Errorf("object has no labels")
+	}
+	for _, k := range sortedKeys(c) {
+		got, ok := labels[k]
+		switch {
+		case !ok:
+			return fmt.Errorf("missing label %v", k)
+		case c[k] != "" && c[k] != got:
+			return fmt.Errorf("label %v=%v, want %v", k, got, c[k])
+		}
+	}
+	return nil
+}
+
+func (c labelCondition) String() string { return fmt.Sprintf("labels %v", map[string]string(c)) }
+
+// Labels returns the labels of an object and true, or false if the object does not have labels.
+// See [LabelCondition] for how labels are found.
+func Labels(o korrel8r.Object) (map[string]string, bool) {
+	if l, ok := o.(interface{ GetLabels() map[string]string }); ok {
+		return l.GetLabels(), true
+	}
+	v := reflect.ValueOf(o)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		v = v.FieldByName("Labels")
+	}
+	if !v.IsValid() || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+		return nil, false
+	}
+	labels := make(map[string]string, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		labels[it.Key().String()] = it.Value().String()
+	}
+	return labels, true
+}
+
+// FieldCondition is true if field, a template that extracts a field value from the start object,
+// evaluates to a non-empty string that matches the regular expression re.
+// The regular expression must match the entire value. A nil re matches any non-empty value.
+//
+// A field template is usually a field expression like `{{.Spec.NodeName}}`.
+func FieldCondition(field *template.Template, re *regexp.Regexp) Condition {
+	c := &fieldCondition{field: field}
+	if re != nil {
+		c.pattern = re.String()
+		c.re = regexp.MustCompile(`^(?:` + c.pattern + `)$`)
+	}
+	return c
+}
+
+type fieldCondition struct {
+	field   *template.Template
+	re      *regexp.Regexp // Anchored regexp.
+	pattern string         // Original regexp pattern.
+}
+
+func (c *fieldCondition) Test(_ korrel8r.Class, start korrel8r.Object) error {
+	b := &bytes.Buffer{}
+	if err := c.field.Execute(b, start); err != nil {
+		return fmt.Errorf("field %v: %w", c.field.Name(), err)
+	}
+	value := strings.TrimSpace(b.String())
+	switch {
+	case value == "" || value == "<no value>":
+		return fmt.Errorf("field %v is empty", c.field.Name())
+	case c.re != nil && !c.re.MatchString(value):
+		return fmt.Errorf("field %v=%v does not match %v", c.field.Name(), value, c.pattern)
+	}
+	return nil
+}
+
+func (c *fieldCondition) String() string {
+	if c.re == nil {
+		return fmt.Sprintf("field %v", c.field.Name())
+	}
+	return fmt.Sprintf("field %v=~%v", c.field.Name(), c.pattern)
+}
+
+// Test all conditions in order, return the first error.
+func testConditions(conditions []Condition, class korrel8r.Class, start korrel8r.Object) error {
+	for _, c := range conditions {
+		if err := c.Test(class, start); err != nil {
+			return fmt.Errorf("%w: %v", ErrNotApplicable, err)
+		}
+	}
+	return nil
+}
+
+// ErrNotApplicable is wrapped by errors from rules that do not apply because a [Condition] is not met.
+var ErrNotApplicable = errors.New("rule does not apply")
+
+// ErrNoQuery is wrapped by errors from rules that generate no query for a start object,
+// for example a blank template or an empty mapping field. The rule does not apply, this is not a failure.
+var ErrNoQuery = errors.New("No query generated")
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
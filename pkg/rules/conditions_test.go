@@ -0,0 +1,74 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/domains/alert"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConditions(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "x", Labels: map[string]string{"app": "foo"}},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	field := func(expr string) *template.Template {
+		return template.Must(template.New(expr).Option("missingkey=error").Parse("{{" + expr + "}}"))
+	}
+	for _, x := range []struct {
+		name   string
+		c      Condition
+		class  korrel8r.Class
+		object korrel8r.Object
+		err    string
+	}{
+		{"class", ClassCondition(a), a, pod, ""},
+		{"class unknown", ClassCondition(a), nil, pod, ""},
+		{"class mismatch", ClassCondition(a), b, pod, "class b is not one of [a]"},
+		{"label", LabelCondition(map[string]string{"app": "foo"}), a, pod, ""},
+		{"label any value", LabelCondition(map[string]string{"app": ""}), a, pod, ""},
+		{"label mismatch", LabelCondition(map[string]string{"app": "bar"}), a, pod, "label app=foo, want bar"},
+		{"label missing", LabelCondition(map[string]string{"x": ""}), a, pod, "missing label x"},
+		{"label field", LabelCondition(map[string]string{"severity": "warning"}), a,
+			&alert.Object{Labels: map[string]string{"severity": "warning"}}, ""},
+		{"no labels", LabelCondition(map[string]string{"x": ""}), a, 42, "object has no labels"},
+		{"field", FieldCondition(field(".Spec.NodeName"), nil), a, pod, ""},
+		{"field match", FieldCondition(field(".Spec.NodeName"), regexp.MustCompile("node.")), a, pod, ""},
+		{"field no match", FieldCondition(field(".Spec.NodeName"), regexp.MustCompile("node")), a, pod,
+			"field .Spec.NodeName=node1 does not match node"},
+		{"field empty", FieldCondition(field(".Spec.Hostname"), nil), a, pod, "field .Spec.Hostname is empty"},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			err := x.c.Test(x.class, x.object)
+			if x.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, x.err)
+			}
+		})
+	}
+}
+
+func TestTemplateRule_When(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	r := NewTemplateRule([]korrel8r.Class{a, b}, []korrel8r.Class{b}, template.Must(template.New("r").Parse("mock:b:{{.}}")),
+		When(ClassCondition(a), LabelCondition(map[string]string{"x": "y"})))
+	obj := map[string]string{"x": "y"}
+	assert.NoError(t, korrel8r.RuleApplies(r, a, obj))
+	err := korrel8r.RuleApplies(r, b, obj)
+	assert.True(t, errors.Is(err, ErrNotApplicable), err)
+	_, err = r.Apply(map[string]string{"x": "z"})
+	assert.EqualError(t, err, "rule does not apply: label x=z, want y")
+}
@@ -0,0 +1,520 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+)
+
+// Severity of a lint [Diagnostic].
+type Severity int
+
+const (
+	// Warning is a problem that affects some, but not all, of the start classes of a rule.
+	Warning Severity = iota
+	// Error is a problem that will prevent the rule from ever applying.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a problem found by [Lint].
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Source   string   `json:"source,omitempty"`  // Source file or URL of the rule, if known.
+	Rule     string   `json:"rule"`              // Rule name.
+	Classes  []string `json:"classes,omitempty"` // Start classes affected by the problem.
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.Source != "" {
+		fmt.Fprintf(&b, "%v: ", d.Source)
+	}
+	fmt.Fprintf(&b, "%v: rule %v: %v", d.Severity, d.Rule, d.Message)
+	if d.Severity == Warning && len(d.Classes) > 0 {
+		const max = 5 // Don't print very long class lists.
+		if len(d.Classes) > max {
+			fmt.Fprintf(&b, " (%v and %v more)", strings.Join(d.Classes[:max], ", "), len(d.Classes)-max)
+		} else {
+			fmt.Fprintf(&b, " (%v)", strings.Join(d.Classes, ", "))
+		}
+	}
+	return b.String()
+}
+
+// Lint statically checks the templates of a rule against the Go type of each of its start classes.
+//
+// It checks the query template of a template rule, the field paths of a mapping rule,
+// and the field expressions of [FieldCondition] preconditions.
+// It checks that field and map key paths like `.Metadata.Namespace` can be evaluated,
+// and that template functions are called with the right number and type of arguments.
+// A problem found for all start classes is an [Error], a problem found for some of them is a [Warning].
+//
+// Funcs is the function map used to parse the rule templates.
+// Classes with no static object type are not checked.
+func Lint(rule korrel8r.Rule, funcs template.FuncMap) []Diagnostic {
+	templates := lintTemplates(rule)
+	problems := map[string][]string{} // Message to list of class names.
+	var order []string                // Messages in order found.
+	start := rule.Start()
+	for _, c := range start {
+		t := ObjectType(c)
+		if t == nil {
+			continue
+		}
+		for _, lt := range templates {
+			l := linter{funcs: funcs, vars: []variable{{"$", t}}}
+			l.walk(lt.tmpl.Tree.Root, t)
+			for _, msg := range l.problems {
+				msg = lt.prefix + msg
+				if _, ok := problems[msg]; !ok {
+					order = append(order, msg)
+				}
+				problems[msg] = append(problems[msg], c.Name())
+			}
+		}
+	}
+	var diagnostics []Diagnostic
+	for _, msg := range order {
+		d := Diagnostic{Severity: Warning, Rule: rule.Name(), Classes: problems[msg], Message: msg}
+		slices.Sort(d.Classes)
+		if len(d.Classes) == len(start) {
+			d.Severity = Error
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// lintTemplate is a template to check, prefix is added to problem messages to show where it came from.
+type lintTemplate struct {
+	prefix string
+	tmpl   *template.Template
+}
+
+// lintTemplates returns the templates of a rule: preconditions first, then the query or mapping fields.
+func lintTemplates(rule korrel8r.Rule) (templates []lintTemplate) {
+	add := func(prefix string, tmpl *template.Template) {
+		if tmpl != nil && tmpl.Tree != nil {
+			templates = append(templates, lintTemplate{prefix: prefix, tmpl: tmpl})
+		}
+	}
+	if o, ok := rule.(interface{ When() []Condition }); ok {
+		for _, c := range o.When() {
+			if fc, ok := c.(*fieldCondition); ok {
+				add("when: ", fc.field)
+			}
+		}
+	}
+	switch r := rule.(type) {
+	case *templateRule:
+		add("", r.query)
+	case *mappingRule:
+		for _, k := range sortedKeys(r.params) {
+			add("mapping "+k+": ", r.params[k])
+		}
+	}
+	return templates
+}
+
+// ObjectType returns the Go type of objects of class c, or nil if it cannot be determined.
+//
+// The type is found by unmarshalling an empty JSON object.
+// Classes that decode to a generic map[string]any have no static type.
+func ObjectType(c korrel8r.Class) reflect.Type {
+	o, err := c.Unmarshal([]byte("{}"))
+	if err != nil || o == nil {
+		return nil
+	}
+	t := reflect.TypeOf(o)
+	if t == genericMap {
+		return nil
+	}
+	return t
+}
+
+var (
+	genericMap = reflect.TypeOf(map[string]any{})
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	boolType   = reflect.TypeOf(true)
+	intType    = reflect.TypeOf(0)
+	stringType = reflect.TypeOf("")
+)
+
+type variable struct {
+	name string
+	t    reflect.Type // nil means unknown type.
+}
+
+// linter walks a template parse tree, tracking the static type of dot and variables.
+// A nil reflect.Type means the type is not known statically, nothing is checked.
+type linter struct {
+	funcs    template.FuncMap
+	vars     []variable
+	problems []string
+}
+
+func (l *linter) errorf(n parse.Node, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if n != nil {
+		msg = fmt.Sprintf("%v: %v", n, msg)
+	}
+	for _, p := range l.problems {
+		if p == msg {
+			return
+		}
+	}
+	l.problems = append(l.problems, msg)
+}
+
+func (l *linter) walk(node parse.Node, dot reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, n := range n.Nodes {
+			l.walk(n, dot)
+		}
+	case *parse.ActionNode:
+		l.pipe(n.Pipe, dot)
+	case *parse.IfNode:
+		l.branch(&n.BranchNode, dot, func(reflect.Type) reflect.Type { return dot })
+	case *parse.WithNode:
+		l.branch(&n.BranchNode, dot, func(t reflect.Type) reflect.Type { return t })
+	case *parse.RangeNode:
+		mark := len(l.vars)
+		t := l.pipeType(n.Pipe, dot)
+		key, elem := rangeTypes(t)
+		if t != nil && key == nil && elem == nil {
+			l.errorf(n.Pipe, "range can't iterate over type %v", t)
+		}
+		switch len(n.Pipe.Decl) {
+		case 1:
+			l.setVar(n.Pipe.Decl[0].Ident[0], elem)
+		case 2:
+			l.setVar(n.Pipe.Decl[0].Ident[0], key)
+			l.setVar(n.Pipe.Decl[1].Ident[0], elem)
+		}
+		l.walk(n.List, elem)
+		l.vars = l.vars[:mark]
+		l.walk(n.ElseList, dot)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			l.pipe(n.Pipe, dot)
+		}
+	}
+}
+
+// branch handles if/with nodes, body computes the type of dot in the body from the pipeline type.
+func (l *linter) branch(n *parse.BranchNode, dot reflect.Type, body func(reflect.Type) reflect.Type) {
+	mark := len(l.vars)
+	t := l.pipeType(n.Pipe, dot)
+	l.declare(n.Pipe, t)
+	l.walk(n.List, body(t))
+	l.vars = l.vars[:mark]
+	l.walk(n.ElseList, dot)
+}
+
+// pipe checks a pipeline and declares its variables.
+func (l *linter) pipe(p *parse.PipeNode, dot reflect.Type) {
+	l.declare(p, l.pipeType(p, dot))
+}
+
+func (l *linter) declare(p *parse.PipeNode, t reflect.Type) {
+	for _, v := range p.Decl {
+		l.setVar(v.Ident[0], t)
+	}
+}
+
+func (l *linter) setVar(name string, t reflect.Type) {
+	for i := len(l.vars) - 1; i >= 0; i-- {
+		if l.vars[i].name == name { // Assignment to existing variable, type may change.
+			l.vars[i].t = t
+			return
+		}
+	}
+	l.vars = append(l.vars, variable{name: name, t: t})
+}
+
+func (l *linter) varType(name string) reflect.Type {
+	for i := len(l.vars) - 1; i >= 0; i-- {
+		if l.vars[i].name == name {
+			return l.vars[i].t
+		}
+	}
+	return nil
+}
+
+// pipeType returns the type of the result of a pipeline.
+func (l *linter) pipeType(p *parse.PipeNode, dot reflect.Type) reflect.Type {
+	if p == nil {
+		return nil
+	}
+	var (
+		t     reflect.Type
+		piped bool
+	)
+	for _, c := range p.Cmds {
+		t = l.cmdType(c, dot, t, piped)
+		piped = true
+	}
+	return t
+}
+
+// cmdType returns the type of the result of a command.
+// If piped is true, the command gets the previous result as an extra final argument of type final.
+func (l *linter) cmdType(c *parse.CommandNode, dot, final reflect.Type, piped bool) reflect.Type {
+	first := c.Args[0]
+	args := c.Args[1:]
+	if id, ok := first.(*parse.IdentifierNode); ok {
+		return l.funcType(id, args, dot, final, piped)
+	}
+	for _, a := range args { // Check argument expressions even though they are passed to a method.
+		l.argType(a, dot)
+	}
+	switch n := first.(type) {
+	case *parse.FieldNode:
+		return l.fieldPath(n, dot, n.Ident)
+	case *parse.ChainNode:
+		return l.fieldPath(n, l.argType(n.Node, dot), n.Field)
+	case *parse.VariableNode:
+		return l.fieldPath(n, l.varType(n.Ident[0]), n.Ident[1:])
+	default:
+		return l.argType(first, dot)
+	}
+}
+
+// argType returns the type of a command argument.
+func (l *linter) argType(n parse.Node, dot reflect.Type) reflect.Type {
+	switch n := n.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return l.fieldPath(n, dot, n.Ident)
+	case *parse.ChainNode:
+		return l.fieldPath(n, l.argType(n.Node, dot), n.Field)
+	case *parse.VariableNode:
+		return l.fieldPath(n, l.varType(n.Ident[0]), n.Ident[1:])
+	case *parse.PipeNode:
+		return l.pipeType(n, dot)
+	case *parse.IdentifierNode:
+		return l.funcType(n, nil, dot, nil, false)
+	case *parse.StringNode:
+		return stringType
+	case *parse.BoolNode:
+		return boolType
+	case *parse.NumberNode:
+		switch {
+		case n.IsInt:
+			return intType
+		case n.IsFloat:
+			return reflect.TypeOf(n.Float64)
+		}
+	}
+	return nil
+}
+
+// builtins maps built-in template functions to their result type, nil if it depends on the arguments.
+var builtins = map[string]reflect.Type{
+	"and": nil, "or": nil, "call": nil, "index": nil, "slice": nil,
+	"not": boolType, "eq": boolType, "ne": boolType, "lt": boolType, "le": boolType, "gt": boolType, "ge": boolType,
+	"len":   intType,
+	"print": stringType, "printf": stringType, "println": stringType,
+	"html": stringType, "js": stringType, "urlquery": stringType,
+}
+
+// funcType checks a function call and returns the type of its result.
+func (l *linter) funcType(id *parse.IdentifierNode, args []parse.Node, dot, final reflect.Type, piped bool) reflect.Type {
+	argTypes := make([]reflect.Type, 0, len(args)+1)
+	for _, a := range args {
+		argTypes = append(argTypes, l.argType(a, dot))
+	}
+	if piped {
+		argTypes = append(argTypes, final)
+	}
+	if t, ok := builtins[id.Ident]; ok {
+		if id.Ident == "index" && len(argTypes) > 0 {
+			return indexType(argTypes[0], len(argTypes)-1)
+		}
+		return t
+	}
+	ft := reflect.TypeOf(l.funcs[id.Ident])
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil // Undefined functions are reported by the template parser.
+	}
+	n := len(argTypes)
+	switch {
+	case ft.IsVariadic() && n < ft.NumIn()-1:
+		l.errorf(id, "function %q wants at least %v arguments, got %v", id.Ident, ft.NumIn()-1, n)
+		return nil
+	case !ft.IsVariadic() && n != ft.NumIn():
+		l.errorf(id, "function %q wants %v arguments, got %v", id.Ident, ft.NumIn(), n)
+		return nil
+	}
+	for i, at := range argTypes {
+		var want reflect.Type
+		if ft.IsVariadic() && i >= ft.NumIn()-1 {
+			want = ft.In(ft.NumIn() - 1).Elem()
+		} else {
+			want = ft.In(i)
+		}
+		if at != nil && !assignable(at, want) {
+			l.errorf(id, "function %q argument %v: can't use type %v as %v", id.Ident, i+1, at, want)
+		}
+	}
+	if ft.NumOut() == 0 {
+		return nil
+	}
+	return known(ft.Out(0))
+}
+
+// fieldPath resolves a sequence of field, method or map key names starting from type t.
+func (l *linter) fieldPath(n parse.Node, t reflect.Type, path []string) reflect.Type {
+	for _, name := range path {
+		if t == nil {
+			return nil
+		}
+		next, err := field(t, name)
+		if err != nil {
+			l.errorf(n, "%v", err)
+			return nil
+		}
+		t = next
+	}
+	return t
+}
+
+// field returns the type of field, method or map key name in type t.
+// Returns nil type if t is not known statically.
+func field(t reflect.Type, name string) (reflect.Type, error) {
+	if m, ok := t.MethodByName(name); ok {
+		return methodType(m, name)
+	}
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		if m, ok := reflect.PointerTo(t).MethodByName(name); ok {
+			return methodType(m, name)
+		}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil, nil // Dynamic type.
+	case reflect.Struct:
+		if f, ok := t.FieldByName(name); ok {
+			if !f.IsExported() {
+				return nil, fmt.Errorf("%v is an unexported field", name)
+			}
+			return known(f.Type), nil
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return known(t.Elem()), nil
+		}
+	}
+	return nil, fmt.Errorf("can't evaluate field %v", name)
+}
+
+func methodType(m reflect.Method, name string) (reflect.Type, error) {
+	mt := m.Type
+	if mt.NumIn() > 1 { // First argument is the receiver.
+		return nil, nil // Method with arguments, not checked.
+	}
+	if mt.NumOut() == 0 || mt.NumOut() > 2 || (mt.NumOut() == 2 && mt.Out(1) != errorType) {
+		return nil, fmt.Errorf("can't call method %v in a template", name)
+	}
+	return known(mt.Out(0)), nil
+}
+
+// known returns t, or nil if t is an interface type so the dynamic type is not known.
+func known(t reflect.Type) reflect.Type {
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+// rangeTypes returns the key and element types for a range over t.
+func rangeTypes(t reflect.Type) (key, elem reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return intType, known(t.Elem())
+	case reflect.Map:
+		return known(t.Key()), known(t.Elem())
+	case reflect.Chan:
+		return nil, known(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return t, t
+	}
+	return nil, nil
+}
+
+// indexType returns the result of the builtin index function with n indices.
+func indexType(t reflect.Type, n int) reflect.Type {
+	for i := 0; i < n && t != nil; i++ {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = known(t.Elem())
+		default:
+			return nil
+		}
+	}
+	return t
+}
+
+// assignable is true if a value of type from can be passed as a template function argument of type to.
+func assignable(from, to reflect.Type) bool {
+	if to.Kind() == reflect.Interface {
+		return from.Implements(to) || reflect.PointerTo(from).Implements(to)
+	}
+	if from.AssignableTo(to) {
+		return true
+	}
+	// Templates dereference pointers and take addresses of addressable values as needed.
+	if from.Kind() == reflect.Pointer && from.Elem().AssignableTo(to) {
+		return true
+	}
+	if to.Kind() == reflect.Pointer && from.AssignableTo(to.Elem()) {
+		return true
+	}
+	// Untyped constants in templates convert to any numeric kind.
+	if from == intType || from.Kind() == reflect.Float64 {
+		switch to.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+			return true
+		}
+	}
+	return false
+}
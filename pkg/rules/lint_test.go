@@ -0,0 +1,108 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/domains/k8s"
+	"github.com/korrel8r/korrel8r/pkg/domains/trace"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	pod, svc := k8s.Domain.Class("Pod"), k8s.Domain.Class("Service")
+	span := trace.Class{}
+	funcs := template.FuncMap{
+		"upper": strings.ToUpper,
+		"get":   func(m map[string]any, k string) any { return m[k] },
+	}
+	for _, x := range []struct {
+		name  string
+		start []korrel8r.Class
+		tmpl  string
+		want  []Diagnostic
+	}{
+		{
+			name:  "ok",
+			start: []korrel8r.Class{pod, svc},
+			tmpl:  `{{.Namespace}}{{.ObjectMeta.Name}}{{range $k, $v := .Labels}}{{upper $k}}{{$v}}{{end}}`,
+		},
+		{
+			name:  "typo",
+			start: []korrel8r.Class{pod, svc},
+			tmpl:  `{{.ObjectMeta.Namspace}}`,
+			want: []Diagnostic{{Severity: Error, Rule: "typo", Classes: []string{pod.Name(), svc.Name()},
+				Message: ".ObjectMeta.Namspace: can't evaluate field Namspace"}},
+		},
+		{
+			name:  "some classes",
+			start: []korrel8r.Class{pod, svc},
+			tmpl:  `{{with .Spec.NodeName}}{{.}}{{end}}`,
+			want: []Diagnostic{{Severity: Warning, Rule: "some classes", Classes: []string{svc.Name()},
+				Message: ".Spec.NodeName: can't evaluate field NodeName"}},
+		},
+		{
+			name:  "map",
+			start: []korrel8r.Class{span},
+			tmpl:  `{{$ns := get .Attributes "k8s.namespace.name"}}{{upper .Name}}{{.Attributes.foo}}`,
+		},
+		{
+			name:  "variable",
+			start: []korrel8r.Class{span},
+			tmpl:  `{{$c := .Context}}{{$c.TraceID}}{{$c.Trace}}`,
+			want: []Diagnostic{{Severity: Error, Rule: "variable", Classes: []string{span.Name()},
+				Message: "$c.Trace: can't evaluate field Trace"}},
+		},
+		{
+			name:  "function args",
+			start: []korrel8r.Class{span},
+			tmpl:  `{{upper .Name "x"}}{{.Context | upper}}`,
+			want: []Diagnostic{
+				{Severity: Error, Rule: "function args", Classes: []string{span.Name()},
+					Message: `upper: function "upper" wants 1 arguments, got 2`},
+				{Severity: Error, Rule: "function args", Classes: []string{span.Name()},
+					Message: `upper: function "upper" argument 1: can't use type trace.SpanContext as string`},
+			},
+		},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			tmpl, err := template.New(x.name).Funcs(funcs).Parse(x.tmpl)
+			require.NoError(t, err)
+			r := NewTemplateRule(x.start, []korrel8r.Class{span}, tmpl)
+			assert.Equal(t, x.want, Lint(r, funcs))
+		})
+	}
+}
+
+func TestLint_fields(t *testing.T) {
+	pod, svc := k8s.Domain.Class("Pod"), k8s.Domain.Class("Service")
+	goal := mock.Domain("mock").Class("x")
+	field := func(path string) *template.Template {
+		expr, err := FieldExpr(path)
+		require.NoError(t, err)
+		return template.Must(template.New(path).Parse("{{" + expr + "}}"))
+	}
+	when := When(FieldCondition(field(".Spec.NodeName"), nil))
+
+	r := NewTemplateRule([]korrel8r.Class{pod, svc}, []korrel8r.Class{goal}, template.Must(template.New("when").Parse(`mock:x:{{.Name}}`)), when)
+	assert.Equal(t, []Diagnostic{{Severity: Warning, Rule: "when", Classes: []string{svc.Name()},
+		Message: "when: .Spec.NodeName: can't evaluate field NodeName"}}, Lint(r, nil))
+
+	r, err := NewMappingRule("mapping", []korrel8r.Class{pod, svc}, goal, map[string]*template.Template{
+		"name":      field(".Name"),
+		"namespace": field(".Namespce"),
+	}, when)
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Severity: Error, Rule: "mapping", Classes: []string{pod.Name(), svc.Name()},
+			Message: "mapping namespace: .Namespce: can't evaluate field Namespce"},
+		{Severity: Warning, Rule: "mapping", Classes: []string{svc.Name()},
+			Message: "when: .Spec.NodeName: can't evaluate field NodeName"},
+	}, Lint(r, nil))
+}
@@ -0,0 +1,133 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r/impl"
+)
+
+// NewMappingRule returns a korrel8r.Rule that copies fields of the start object into goal query parameters.
+//
+// Params maps goal query parameter names to templates that extract a field value from the start object,
+// see [FieldExpr]. The goal domain must implement [korrel8r.QueryBuilder], it builds the query.
+// The rule does not apply if any field value is empty, except for parameters named by the [Optional] option,
+// which are left out of the query. The rule does not apply if all field values are empty.
+func NewMappingRule(name string, start []korrel8r.Class, goal korrel8r.Class, params map[string]*template.Template, opts ...Option) (korrel8r.Rule, error) {
+	qb, ok := goal.Domain().(korrel8r.QueryBuilder)
+	if !ok {
+		return nil, fmt.Errorf("rule %v: domain %v does not support mapping rules", name, goal.Domain())
+	}
+	r := &mappingRule{name: name, start: start, goal: goal, params: params, builder: qb}
+	r.apply(opts)
+	return r, nil
+}
+
+var (
+	_                          = impl.AssertRule(&mappingRule{})
+	_ korrel8r.ConditionalRule = &mappingRule{}
+	_ korrel8r.PriorityRule    = &mappingRule{}
+)
+
+type mappingRule struct {
+	options
+	name    string
+	start   []korrel8r.Class
+	goal    korrel8r.Class
+	params  map[string]*template.Template
+	builder korrel8r.QueryBuilder
+}
+
+func (r *mappingRule) Name() string            { return r.name }
+func (r *mappingRule) String() string          { return r.Name() }
+func (r *mappingRule) Start() []korrel8r.Class { return r.start }
+func (r *mappingRule) Goal() []korrel8r.Class  { return []korrel8r.Class{r.goal} }
+
+// Apply the rule by extracting fields from start and building a goal query.
+func (r *mappingRule) Apply(start korrel8r.Object) (korrel8r.Query, error) {
+	if err := r.Applies(nil, start); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(r.params))
+	b := &bytes.Buffer{}
+	for _, k := range sortedKeys(r.params) {
+		b.Reset()
+		field := r.params[k]
+		if err := field.Execute(b, start); err != nil {
+			return nil, fmt.Errorf("field %v: %w", field.Name(), err)
+		}
+		v := strings.TrimSpace(b.String())
+		switch {
+		case v != "" && v != "<no value>":
+			values[k] = v
+		case !slices.Contains(r.optional, k):
+			return nil, fmt.Errorf("%w: field %v is empty", ErrNoQuery, field.Name())
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: all fields are empty", ErrNoQuery)
+	}
+	return r.builder.BuildQuery(r.goal, values)
+}
+
+// FieldExpr converts a field path to a template expression.
+//
+// A path is a sequence of field names and map keys, for example:
+//
+//	.Labels.namespace
+//	.Attributes["k8s.pod.name"]
+//
+// The leading '.' is optional. Map keys are double-quoted Go strings.
+func FieldExpr(path string) (string, error) {
+	expr, simple := ".", true
+	s := path
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			i := 1
+			for i < len(s) && (s[i] == '_' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			if i == 1 {
+				return "", fmt.Errorf("invalid field path %q: missing field name", path)
+			}
+			switch {
+			case expr == ".":
+				expr = s[:i]
+			case simple:
+				expr += s[:i]
+			default:
+				expr = "(" + expr + ")" + s[:i]
+			}
+			simple = true
+			s = s[i:]
+		case '[':
+			key, err := strconv.QuotedPrefix(s[1:])
+			if err != nil || !strings.HasPrefix(s[1+len(key):], "]") {
+				return "", fmt.Errorf("invalid field path %q: map key must be a quoted string in []", path)
+			}
+			if !simple {
+				expr = "(" + expr + ")"
+			}
+			expr, simple = "index "+expr+" "+key, false
+			s = s[len(key)+2:]
+		default:
+			return "", fmt.Errorf("invalid field path %q: unexpected %q", path, s[0])
+		}
+	}
+	if expr == "." {
+		return "", fmt.Errorf("invalid field path %q: empty", path)
+	}
+	return expr, nil
+}
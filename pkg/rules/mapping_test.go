@@ -0,0 +1,87 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldExpr(t *testing.T) {
+	for _, x := range []struct{ path, want, err string }{
+		{path: ".Labels.namespace", want: ".Labels.namespace"},
+		{path: "Labels.namespace", want: ".Labels.namespace"},
+		{path: `.Attributes["k8s.pod.name"]`, want: `index .Attributes "k8s.pod.name"`},
+		{path: `Attributes["a"]["b"].X`, want: `(index (index .Attributes "a") "b").X`},
+		{path: `["x"]`, want: `index . "x"`},
+		{path: "", err: `invalid field path "": empty`},
+		{path: ".a..b", err: `invalid field path ".a..b": missing field name`},
+		{path: `.a[x]`, err: `invalid field path ".a[x]": map key must be a quoted string in []`},
+		{path: `.a b`, err: `invalid field path ".a b": unexpected ' '`},
+	} {
+		t.Run(x.path, func(t *testing.T) {
+			got, err := FieldExpr(x.path)
+			if x.err != "" {
+				assert.EqualError(t, err, x.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, x.want, got)
+			}
+		})
+	}
+}
+
+func TestMappingRule(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	field := func(path string) *template.Template {
+		expr, err := FieldExpr(path)
+		require.NoError(t, err)
+		return template.Must(template.New(path).Parse("{{" + expr + "}}"))
+	}
+	r, err := NewMappingRule("m", []korrel8r.Class{a}, b, map[string]*template.Template{
+		"namespace": field(`.Attributes["k8s.namespace.name"]`),
+		"name":      field(`.Attributes["k8s.pod.name"]`),
+	})
+	require.NoError(t, err)
+
+	q, err := r.Apply(map[string]any{"Attributes": map[string]any{"k8s.namespace.name": "ns", "k8s.pod.name": "p"}})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(b, "name=p,namespace=ns"), q)
+
+	_, err = r.Apply(map[string]any{"Attributes": map[string]any{"k8s.namespace.name": "ns"}})
+	assert.EqualError(t, err, `No query generated: field .Attributes["k8s.pod.name"] is empty`)
+	assert.ErrorIs(t, err, ErrNoQuery)
+}
+
+func TestMappingRule_optional(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	field := func(path string) *template.Template {
+		expr, err := FieldExpr(path)
+		require.NoError(t, err)
+		return template.Must(template.New(path).Parse("{{" + expr + "}}"))
+	}
+	r, err := NewMappingRule("m", []korrel8r.Class{a}, b, map[string]*template.Template{
+		"namespace": field(`.Namespace`),
+		"name":      field(`.Name`),
+	}, Optional("namespace", "name"))
+	require.NoError(t, err)
+
+	q, err := r.Apply(map[string]any{"Namespace": "ns", "Name": "p"})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(b, "name=p,namespace=ns"), q)
+
+	q, err = r.Apply(map[string]any{"Namespace": "ns", "Name": ""})
+	require.NoError(t, err)
+	assert.Equal(t, mock.NewQuery(b, "namespace=ns"), q)
+
+	_, err = r.Apply(map[string]any{"Namespace": "", "Name": ""})
+	assert.EqualError(t, err, `No query generated: all fields are empty`)
+	assert.ErrorIs(t, err, ErrNoQuery)
+}
@@ -0,0 +1,167 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r/impl"
+)
+
+// Plugin is a loaded WebAssembly rule module.
+//
+// A plugin module is a WASI command: it reads a JSON-encoded start object from standard input,
+// and writes goal queries to standard output. Each query starts on a new line beginning
+// with the goal domain name, as for template rules. Blank output means the rule does not apply.
+// Anything written to standard error is included in the error if the module fails.
+type Plugin interface {
+	// Call runs the module with start as input, returns the module output.
+	Call(ctx context.Context, start []byte) ([]byte, error)
+}
+
+// PluginLimits are resource limits for a plugin module.
+type PluginLimits struct {
+	// Memory is the maximum memory in bytes the module can use.
+	Memory uint64
+	// Timeout is the maximum time for a single call to the module.
+	Timeout time.Duration
+}
+
+// Default plugin limits, used for limits that are not set.
+const (
+	DefaultPluginMemory  = 16 * 1024 * 1024
+	DefaultPluginTimeout = time.Second
+)
+
+// PluginRuntime compiles WebAssembly modules in a sandbox.
+// The runtime must enforce the limits, and must not give modules access to the host
+// file system, network or environment.
+type PluginRuntime interface {
+	// Load compiles a WebAssembly module. Name identifies the module in errors.
+	Load(ctx context.Context, name string, wasm []byte, limits PluginLimits) (Plugin, error)
+}
+
+// ErrNoPluginRuntime is returned by [LoadPlugin] if the plugin runtime has been set to nil.
+var ErrNoPluginRuntime = errors.New("WebAssembly rule plugins are not supported: no plugin runtime")
+
+var pluginRuntime PluginRuntime = WazeroRuntime{}
+
+// SetPluginRuntime replaces the runtime used by [LoadPlugin], the default is [WazeroRuntime].
+// It should be called during program initialization.
+func SetPluginRuntime(r PluginRuntime) { pluginRuntime = r }
+
+// LoadPlugin loads a WebAssembly module using the registered [PluginRuntime].
+// Zero values in limits are replaced by defaults.
+// The returned plugin implements [io.Closer] to release the module.
+func LoadPlugin(ctx context.Context, name string, wasm []byte, limits PluginLimits) (Plugin, error) {
+	if pluginRuntime == nil {
+		return nil, ErrNoPluginRuntime
+	}
+	if limits.Memory == 0 {
+		limits.Memory = DefaultPluginMemory
+	}
+	if limits.Timeout == 0 {
+		limits.Timeout = DefaultPluginTimeout
+	}
+	p, err := pluginRuntime.Load(ctx, name, wasm, limits)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %v: %w", name, err)
+	}
+	return &limitedPlugin{Plugin: p, timeout: limits.Timeout}, nil
+}
+
+// limitedPlugin has the call timeout for a plugin.
+type limitedPlugin struct {
+	Plugin
+	timeout time.Duration
+}
+
+func (p *limitedPlugin) Timeout() time.Duration { return p.timeout }
+
+func (p *limitedPlugin) Close() error {
+	if c, ok := p.Plugin.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewPluginRule returns a korrel8r.Rule that calls a WebAssembly [Plugin] to transform objects to queries.
+func NewPluginRule(name string, start, goal []korrel8r.Class, plugin Plugin, opts ...Option) korrel8r.Rule {
+	r := &pluginRule{name: name, start: start, goal: goal, plugin: plugin}
+	r.apply(opts)
+	return r
+}
+
+var (
+	_                          = impl.AssertRule(&pluginRule{})
+	_ korrel8r.MultiRule       = &pluginRule{}
+	_ korrel8r.ContextRule     = &pluginRule{}
+	_ korrel8r.ConditionalRule = &pluginRule{}
+	_ korrel8r.PriorityRule    = &pluginRule{}
+)
+
+type pluginRule struct {
+	options
+	name        string
+	plugin      Plugin
+	start, goal []korrel8r.Class
+}
+
+func (r *pluginRule) Name() string            { return r.name }
+func (r *pluginRule) String() string          { return r.Name() }
+func (r *pluginRule) Start() []korrel8r.Class { return r.start }
+func (r *pluginRule) Goal() []korrel8r.Class  { return r.goal }
+
+// Close the plugin if it is an [io.Closer].
+func (r *pluginRule) Close() error {
+	if c, ok := r.plugin.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Apply the rule by calling the plugin.
+// Return non-nil error if the rule does not apply, or generates more than one query.
+func (r *pluginRule) Apply(start korrel8r.Object) (korrel8r.Query, error) {
+	queries, err := r.ApplyAll(start)
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) > 1 {
+		return nil, fmt.Errorf("Multiple queries generated: %v", len(queries))
+	}
+	return queries[0], nil
+}
+
+// ApplyAll calls the plugin and returns all the queries generated.
+func (r *pluginRule) ApplyAll(start korrel8r.Object) ([]korrel8r.Query, error) {
+	return r.ApplyContext(context.Background(), start)
+}
+
+// ApplyContext calls the plugin, the call is stopped when ctx is done or the plugin timeout expires.
+func (r *pluginRule) ApplyContext(ctx context.Context, start korrel8r.Object) ([]korrel8r.Query, error) {
+	if err := r.Applies(nil, start); err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(start)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(DefaultPluginTimeout)
+	if t, ok := r.plugin.(interface{ Timeout() time.Duration }); ok {
+		timeout = t.Timeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output, err := r.plugin.Call(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return parseQueries(r.Goal()[0].Domain(), string(output))
+}
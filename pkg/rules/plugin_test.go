@@ -0,0 +1,90 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// funcPlugin is a fake plugin that calls a Go function.
+type funcPlugin func(ctx context.Context, start []byte) ([]byte, error)
+
+func (f funcPlugin) Call(ctx context.Context, start []byte) ([]byte, error) { return f(ctx, start) }
+
+// fakeRuntime "compiles" a module by looking up its contents in a map of plugins.
+type fakeRuntime struct {
+	plugins map[string]funcPlugin
+	limits  PluginLimits
+}
+
+func (r *fakeRuntime) Load(_ context.Context, _ string, wasm []byte, limits PluginLimits) (Plugin, error) {
+	r.limits = limits
+	if p, ok := r.plugins[string(wasm)]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("invalid module")
+}
+
+func TestPluginRule(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b, c := d.Class("a"), d.Class("b"), d.Class("c")
+	rt := &fakeRuntime{plugins: map[string]funcPlugin{
+		"split": func(_ context.Context, start []byte) ([]byte, error) {
+			var s []string
+			if err := json.Unmarshal(start, &s); err != nil {
+				return nil, err
+			}
+			return []byte(fmt.Sprintf("mock:b:%v\nmock:c:%v", s[0], s[1])), nil
+		},
+		"blank": func(context.Context, []byte) ([]byte, error) { return []byte("\n"), nil },
+		"slow": func(ctx context.Context, _ []byte) ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}}
+
+	defer SetPluginRuntime(pluginRuntime)
+	SetPluginRuntime(nil)
+	_, err := LoadPlugin(context.Background(), "x", []byte("split"), PluginLimits{})
+	require.ErrorIs(t, err, ErrNoPluginRuntime)
+
+	SetPluginRuntime(rt)
+	_, err = LoadPlugin(context.Background(), "x", []byte("nonsense"), PluginLimits{})
+	assert.EqualError(t, err, "plugin x: invalid module")
+	assert.Equal(t, PluginLimits{Memory: DefaultPluginMemory, Timeout: DefaultPluginTimeout}, rt.limits)
+
+	load := func(module string, timeout time.Duration) korrel8r.Rule {
+		p, err := LoadPlugin(context.Background(), module, []byte(module), PluginLimits{Timeout: timeout})
+		require.NoError(t, err)
+		return NewPluginRule(module, []korrel8r.Class{a}, []korrel8r.Class{b, c}, p)
+	}
+
+	t.Run("split", func(t *testing.T) {
+		got, err := korrel8r.ApplyRule(load("split", 0), []string{"x", "y"})
+		require.NoError(t, err)
+		assert.Equal(t, []korrel8r.Query{mock.NewQuery(b, "x"), mock.NewQuery(c, "y")}, got)
+	})
+	t.Run("blank", func(t *testing.T) {
+		_, err := korrel8r.ApplyRule(load("blank", 0), "x")
+		assert.EqualError(t, err, "No query generated")
+	})
+	t.Run("timeout", func(t *testing.T) {
+		_, err := korrel8r.ApplyRule(load("slow", time.Millisecond), "x")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("when", func(t *testing.T) {
+		p, err := LoadPlugin(context.Background(), "split", []byte("split"), PluginLimits{})
+		require.NoError(t, err)
+		r := NewPluginRule("split", []korrel8r.Class{a}, []korrel8r.Class{b}, p, When(ClassCondition(b)))
+		assert.ErrorIs(t, korrel8r.RuleApplies(r, a, "x"), ErrNotApplicable)
+	})
+}
@@ -6,9 +6,12 @@
 package rules
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"slices"
 	"strings"
 	"text/template"
+	"text/template/parse"
 
 	"bytes"
 
@@ -17,13 +20,82 @@ import (
 )
 
 // NewTemplateRule returns a korrel8r.Rule that uses Go templates to transform objects to queries.
-func NewTemplateRule(start, goal []korrel8r.Class, query *template.Template) korrel8r.Rule {
-	return &templateRule{start: start, goal: goal, query: query}
+func NewTemplateRule(start, goal []korrel8r.Class, query *template.Template, opts ...Option) korrel8r.Rule {
+	r := &templateRule{start: start, goal: goal, query: query}
+	r.apply(opts)
+	if r.contextFuncs != nil {
+		var names []string
+		for name := range r.contextFuncs(context.Background()) {
+			names = append(names, name)
+		}
+		if !Calls(query, names...) {
+			r.contextFuncs = nil // No need to clone the template in ApplyContext.
+		}
+	}
+	return r
+}
+
+// Option for [NewTemplateRule] and [NewPluginRule].
+type Option func(*options)
+
+// When option: the rule only applies to start objects that satisfy all the conditions.
+func When(conditions ...Condition) Option {
+	return func(o *options) { o.when = append(o.when, conditions...) }
+}
+
+// Priority option: set the priority and exclusive flag, see [korrel8r.PriorityRule].
+func Priority(priority int, exclusive bool) Option {
+	return func(o *options) { o.priority, o.exclusive = priority, exclusive }
 }
 
-var _ = impl.AssertRule(&templateRule{})
+// ContextFuncs option: template functions that use the context of [korrel8r.ContextRule.ApplyContext],
+// they replace functions with the same names. Only used by template rules.
+func ContextFuncs(funcs func(context.Context) template.FuncMap) Option {
+	return func(o *options) { o.contextFuncs = funcs }
+}
+
+// Optional option: mapping parameters that are left out of the query if their field is empty,
+// instead of the rule not applying. Only used by mapping rules.
+func Optional(params ...string) Option {
+	return func(o *options) { o.optional = append(o.optional, params...) }
+}
+
+// options common to all rule types.
+type options struct {
+	when         []Condition
+	priority     int
+	exclusive    bool
+	contextFuncs func(context.Context) template.FuncMap
+	optional     []string
+}
+
+func (o *options) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+func (o *options) Priority() int   { return o.priority }
+func (o *options) Exclusive() bool { return o.exclusive }
+
+// When returns the rule's preconditions.
+func (o *options) When() []Condition { return o.when }
+
+// Applies tests the rule conditions, returns an error wrapping [ErrNotApplicable] if they are not met.
+func (o *options) Applies(class korrel8r.Class, start korrel8r.Object) error {
+	return testConditions(o.when, class, start)
+}
+
+var (
+	_                          = impl.AssertRule(&templateRule{})
+	_ korrel8r.MultiRule       = &templateRule{}
+	_ korrel8r.ContextRule     = &templateRule{}
+	_ korrel8r.ConditionalRule = &templateRule{}
+	_ korrel8r.PriorityRule    = &templateRule{}
+)
 
 type templateRule struct {
+	options
 	query       *template.Template
 	start, goal []korrel8r.Class
 }
@@ -34,15 +106,138 @@ func (r *templateRule) Start() []korrel8r.Class { return r.start }
 func (r *templateRule) Goal() []korrel8r.Class  { return r.goal }
 
 // Apply the rule by applying the template.
-// Return non-nil error if the rule does not apply.
+// Return non-nil error if the rule does not apply, or generates more than one query.
 func (r *templateRule) Apply(start korrel8r.Object) (korrel8r.Query, error) {
+	queries, err := r.ApplyAll(start)
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) > 1 {
+		return nil, fmt.Errorf("Multiple queries generated: %v", len(queries))
+	}
+	return queries[0], nil
+}
+
+// ApplyAll applies the template and returns all the queries generated.
+// Each query starts on a new line beginning with the goal domain name.
+// Return non-nil error if the rule does not apply or any query is invalid.
+func (r *templateRule) ApplyAll(start korrel8r.Object) ([]korrel8r.Query, error) {
+	return r.execute(r.query, start)
+}
+
+// ApplyContext is like ApplyAll, template functions from the [ContextFuncs] option use ctx.
+func (r *templateRule) ApplyContext(ctx context.Context, start korrel8r.Object) ([]korrel8r.Query, error) {
+	tmpl := r.query
+	if r.contextFuncs != nil {
+		var err error
+		if tmpl, err = r.query.Clone(); err != nil {
+			return nil, err
+		}
+		tmpl.Funcs(r.contextFuncs(ctx))
+	}
+	return r.execute(tmpl, start)
+}
+
+// Calls returns true if any template associated with tmpl calls any of the named functions.
+func Calls(tmpl *template.Template, funcs ...string) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && calls(t.Tree.Root, funcs) {
+			return true
+		}
+	}
+	return false
+}
+
+// calls returns true if a template node calls any of the named functions.
+func calls(node parse.Node, funcs []string) bool {
+	switch n := node.(type) {
+	case *parse.IdentifierNode:
+		return slices.Contains(funcs, n.Ident)
+	case *parse.ListNode:
+		if n != nil {
+			for _, n := range n.Nodes {
+				if calls(n, funcs) {
+					return true
+				}
+			}
+		}
+	case *parse.ActionNode:
+		return calls(n.Pipe, funcs)
+	case *parse.PipeNode:
+		if n != nil {
+			for _, c := range n.Cmds {
+				if calls(c, funcs) {
+					return true
+				}
+			}
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			if calls(a, funcs) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return calls(n.Node, funcs)
+	case *parse.IfNode:
+		return calls(&n.BranchNode, funcs)
+	case *parse.RangeNode:
+		return calls(&n.BranchNode, funcs)
+	case *parse.WithNode:
+		return calls(&n.BranchNode, funcs)
+	case *parse.BranchNode:
+		return calls(n.Pipe, funcs) || calls(n.List, funcs) || calls(n.ElseList, funcs)
+	case *parse.TemplateNode:
+		return calls(n.Pipe, funcs)
+	}
+	return false
+}
+
+func (r *templateRule) execute(tmpl *template.Template, start korrel8r.Object) ([]korrel8r.Query, error) {
+	if err := r.Applies(nil, start); err != nil {
+		return nil, err
+	}
 	b := &bytes.Buffer{}
-	if err := r.query.Execute(b, start); err != nil {
+	if err := tmpl.Execute(b, start); err != nil {
 		return nil, err
 	}
-	query := strings.TrimSpace(string(b.String()))
-	if query == "" { // Blank query means rule does not apply.
-		return nil, errors.New("No query generated")
+	return parseQueries(r.Goal()[0].Domain(), b.String())
+}
+
+// parseQueries parses text containing one or more queries for domain, see [splitQueries].
+// Returns an error if there are no queries or any query is invalid.
+func parseQueries(domain korrel8r.Domain, text string) ([]korrel8r.Query, error) {
+	var queries []korrel8r.Query
+	for _, s := range splitQueries(text, domain.Name()+korrel8r.NameSeparator) {
+		q, err := domain.Query(s)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	if len(queries) == 0 { // Blank query means rule does not apply.
+		return nil, ErrNoQuery
+	}
+	return queries, nil
+}
+
+// splitQueries splits text into query strings.
+// A line beginning with prefix starts a new query, other lines continue the current query.
+// Blank queries are omitted.
+func splitQueries(text, prefix string) (queries []string) {
+	var current []string
+	add := func() {
+		if q := strings.TrimSpace(strings.Join(current, "\n")); q != "" {
+			queries = append(queries, q)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+			add()
+		}
+		current = append(current, line)
 	}
-	return r.Goal()[0].Domain().Query(query)
+	add()
+	return queries
 }
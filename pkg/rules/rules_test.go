@@ -0,0 +1,83 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRule_ApplyAll(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b, c := d.Class("a"), d.Class("b"), d.Class("c")
+	for _, x := range []struct {
+		name, tmpl string
+		want       []korrel8r.Query
+		err        string
+	}{
+		{name: "one", tmpl: `mock:b:{{.}}`, want: []korrel8r.Query{mock.NewQuery(b, "x")}},
+		{
+			name: "many",
+			tmpl: "mock:b:{{.}}\n\n  mock:c:{{.}}\nmore\nmock:b:y",
+			want: []korrel8r.Query{mock.NewQuery(b, "x"), mock.NewQuery(c, "x\nmore"), mock.NewQuery(b, "y")},
+		},
+		{name: "blank", tmpl: "  \n ", err: "No query generated"},
+	} {
+		t.Run(x.name, func(t *testing.T) {
+			r := NewTemplateRule([]korrel8r.Class{a}, []korrel8r.Class{b, c}, template.Must(template.New(x.name).Parse(x.tmpl)))
+			got, err := korrel8r.ApplyRule(r, "x")
+			if x.err != "" {
+				assert.EqualError(t, err, x.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, x.want, got)
+			if len(x.want) == 1 {
+				q, err := r.Apply("x")
+				require.NoError(t, err)
+				assert.Equal(t, x.want[0], q)
+			} else {
+				_, err := r.Apply("x")
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTemplateRule_ApplyContext(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	type key struct{}
+	tmpl := template.Must(template.New("x").Funcs(template.FuncMap{"user": func() string { return "none" }}).Parse(`mock:b:{{user}}`))
+	r := NewTemplateRule([]korrel8r.Class{a}, []korrel8r.Class{b}, tmpl, ContextFuncs(func(ctx context.Context) template.FuncMap {
+		return template.FuncMap{"user": func() string { return ctx.Value(key{}).(string) }}
+	}))
+	got, err := korrel8r.ApplyRuleContext(context.WithValue(context.Background(), key{}, "alice"), r, "x")
+	require.NoError(t, err)
+	assert.Equal(t, []korrel8r.Query{mock.NewQuery(b, "alice")}, got)
+	got, err = korrel8r.ApplyRule(r, "x") // Without a context, uses the original functions.
+	require.NoError(t, err)
+	assert.Equal(t, []korrel8r.Query{mock.NewQuery(b, "none")}, got)
+}
+
+func TestTemplateRule_ApplyContext_noContextFuncs(t *testing.T) {
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	tmpl := template.Must(template.New("x").Funcs(template.FuncMap{"user": func() string { return "none" }}).Parse(`mock:b:{{.}}`))
+	called := 0
+	r := NewTemplateRule([]korrel8r.Class{a}, []korrel8r.Class{b}, tmpl, ContextFuncs(func(ctx context.Context) template.FuncMap {
+		called++
+		return template.FuncMap{"user": func() string { return "alice" }}
+	}))
+	called = 0
+	got, err := korrel8r.ApplyRuleContext(context.Background(), r, "x")
+	require.NoError(t, err)
+	assert.Equal(t, []korrel8r.Query{mock.NewQuery(b, "x")}, got)
+	assert.Zero(t, called, "template does not call context functions, not cloned")
+}
@@ -0,0 +1,34 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+// Test plugin: reads a JSON string and behaves according to its value.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+var keep [][]byte
+
+func main() {
+	var s string
+	if err := json.NewDecoder(os.Stdin).Decode(&s); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch s {
+	case "loop":
+		for {
+		}
+	case "alloc":
+		for {
+			keep = append(keep, make([]byte, 1024*1024))
+		}
+	case "fail":
+		fmt.Fprintln(os.Stderr, "failed")
+		os.Exit(1)
+	default:
+		fmt.Printf("mock:b:%v\n", s)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// WazeroRuntime is the default [PluginRuntime], using the pure-Go [wazero] runtime.
+//
+// Each module gets its own runtime with a memory limit, the module is instantiated for each call.
+// Modules get standard input, output and error only: no file system, network, environment or real clocks.
+// A call is stopped when its context is done.
+//
+// [wazero]: https://wazero.io
+type WazeroRuntime struct{}
+
+// Size of a WebAssembly memory page in bytes, and the maximum number of pages.
+const (
+	wasmPageSize = 64 * 1024
+	maxWasmPages = 65536
+)
+
+// wazeroCache shares compiled code between runtimes, so reloading a module is fast.
+var wazeroCache = wazero.NewCompilationCache()
+
+// Load compiles a module. The memory limit is rounded up to a whole number of pages.
+func (WazeroRuntime) Load(ctx context.Context, name string, wasm []byte, limits PluginLimits) (Plugin, error) {
+	pages := (limits.Memory + wasmPageSize - 1) / wasmPageSize
+	pages = min(max(pages, 1), maxWasmPages)
+	cfg := wazero.NewRuntimeConfig().WithMemoryLimitPages(uint32(pages)).WithCloseOnContextDone(true).
+		WithCompilationCache(wazeroCache)
+	rt := wazero.NewRuntimeWithConfig(ctx, cfg)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	module, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	return &wazeroPlugin{name: name, runtime: rt, module: module}, nil
+}
+
+type wazeroPlugin struct {
+	name    string
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+}
+
+func (p *wazeroPlugin) Call(ctx context.Context, start []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().
+		WithName(""). // Anonymous, so the module can be instantiated concurrently.
+		WithArgs(p.name).
+		WithStdin(bytes.NewReader(start)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+	m, err := p.runtime.InstantiateModule(ctx, p.module, cfg)
+	if m != nil {
+		_ = m.Close(ctx)
+	}
+	if exit := (*sys.ExitError)(nil); errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil // Normal exit.
+	}
+	switch {
+	case err == nil:
+		return stdout.Bytes(), nil
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case stderr.Len() > 0:
+		return nil, fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+	default:
+		return nil, err
+	}
+}
+
+// Close releases the module and its runtime.
+func (p *wazeroPlugin) Close() error { return p.runtime.Close(context.Background()) }
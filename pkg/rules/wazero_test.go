@@ -0,0 +1,66 @@
+// Copyright: This file is part of korrel8r, released under https://github.com/korrel8r/korrel8r/blob/main/LICENSE
+
+package rules
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/korrel8r/korrel8r/internal/pkg/test/mock"
+	"github.com/korrel8r/korrel8r/pkg/korrel8r"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildPlugin compiles testdata/plugin to WebAssembly.
+func buildPlugin(t *testing.T) []byte {
+	t.Helper()
+	wasm := filepath.Join(t.TempDir(), "plugin.wasm")
+	cmd := exec.Command("go", "build", "-o", wasm, "./testdata/plugin")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build wasip1 plugin: %v\n%s", err, out)
+	}
+	b, err := os.ReadFile(wasm)
+	require.NoError(t, err)
+	return b
+}
+
+func TestWazeroRuntime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a WebAssembly module")
+	}
+	wasm := buildPlugin(t)
+	d := mock.Domain("mock")
+	a, b := d.Class("a"), d.Class("b")
+	load := func(limits PluginLimits) korrel8r.Rule {
+		p, err := LoadPlugin(context.Background(), "test", wasm, limits)
+		require.NoError(t, err)
+		r := NewPluginRule("test", []korrel8r.Class{a}, []korrel8r.Class{b}, p)
+		t.Cleanup(func() { _ = r.(*pluginRule).Close() })
+		return r
+	}
+	r := load(PluginLimits{})
+
+	t.Run("query", func(t *testing.T) {
+		got, err := korrel8r.ApplyRule(r, "x")
+		require.NoError(t, err)
+		assert.Equal(t, []korrel8r.Query{mock.NewQuery(b, "x")}, got)
+	})
+	t.Run("error", func(t *testing.T) {
+		_, err := korrel8r.ApplyRule(r, "fail")
+		assert.ErrorContains(t, err, "failed")
+	})
+	t.Run("timeout", func(t *testing.T) {
+		_, err := korrel8r.ApplyRule(load(PluginLimits{Timeout: 100 * time.Millisecond}), "loop")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("memory", func(t *testing.T) {
+		_, err := korrel8r.ApplyRule(load(PluginLimits{Memory: 32 * 1024 * 1024}), "alloc")
+		assert.ErrorContains(t, err, "out of memory")
+	})
+}